@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+const (
+	accountPurgeInterval = 10 * time.Minute
+	accountPurgeBatch    = 50
+)
+
+var errAccountClosing = errors.New("account is scheduled for deletion")
+
+// deleteAccount schedules the logged-in user's account for deletion after
+// the grace period and logs them out everywhere. Logging in again before
+// then cancels the deletion.
+func (c *apiConfig) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var p deleteAccountParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	if p.Password == "" {
+		respondWithFieldErrors(w, "Invalid request", map[string]string{"password": "is required to delete your account"})
+		return
+	}
+
+	tx, err := c.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, "Failed to delete account", http.StatusInternalServerError)
+		log.Printf("Error starting transaction: %v", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
+
+	current, err := qtx.GetUserByIDForUpdate(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, "Failed to delete account", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+	if auth.CheckPasswordHash(p.Password, current.HashedPassword) != nil {
+		respondWithError(w, "Password is incorrect", http.StatusForbidden)
+		return
+	}
+
+	user, err := qtx.ScheduleUserDeletion(r.Context(), database.ScheduleUserDeletionParams{
+		ID:                  userID,
+		DeletionScheduledAt: sql.NullTime{Time: time.Now().Add(c.deletionGrace), Valid: true},
+	})
+	if err == nil {
+		err = qtx.RevokeAllRefreshTokens(r.Context(), userID)
+	}
+	if err != nil {
+		respondWithError(w, "Failed to delete account", http.StatusInternalServerError)
+		log.Printf("Error scheduling account deletion: %v", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, "Failed to delete account", http.StatusInternalServerError)
+		log.Printf("Error committing account deletion: %v", err)
+		return
+	}
+
+	JSONUser, err := c.userResponse(user)
+	if err != nil {
+		respondWithError(w, "Failed to create user response", http.StatusInternalServerError)
+		log.Printf("Error creating user response: %v", err)
+		return
+	}
+	respondWithJSON(w, JSONUser, http.StatusAccepted)
+}
+
+// purgeDeletedAccounts deletes accounts whose grace period has run out
+// until ctx is cancelled.
+func (c *apiConfig) purgeDeletedAccounts(ctx context.Context) {
+	ticker := time.NewTicker(accountPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			users, err := c.dbQueries.GetUsersDueForDeletion(ctx, accountPurgeBatch)
+			if err != nil {
+				log.Printf("Error retrieving accounts due for deletion: %v", err)
+				continue
+			}
+			for _, user := range users {
+				if err := c.purgeAccount(ctx, user.ID); err != nil {
+					log.Printf("Error deleting account %s: %v", user.ID, err)
+				}
+			}
+		}
+	}
+}
+
+// purgeAccount deletes a user whose deletion is due. Their chirps are
+// deleted explicitly so stream subscribers hear about it, and remote
+// followers are sent a Delete of the account; everything else that belongs
+// to the user goes with the row.
+func (c *apiConfig) purgeAccount(ctx context.Context, userID uuid.UUID) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
+
+	// The lock makes a concurrent login either cancel the deletion first
+	// or wait until the account is gone.
+	user, err := qtx.GetUserByIDForUpdate(ctx, userID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.DeletionScheduledAt.Valid || user.DeletionScheduledAt.Time.After(time.Now()) {
+		return nil
+	}
+
+	deliveries, err := c.accountDeleteDeliveries(ctx, qtx, userID)
+	if err != nil {
+		return err
+	}
+	chirps, err := qtx.DeleteUserChirps(ctx, userID)
+	if err != nil {
+		return err
+	}
+	exports, err := qtx.DeleteUserDataExports(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := qtx.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, chirp := range chirps {
+		c.publishChirpDeleted(chirp)
+	}
+	for _, d := range deliveries {
+		c.federation.Enqueue(d)
+	}
+	c.removeExportFiles(exports)
+	for _, key := range []sql.NullString{user.AvatarKey, user.BannerKey} {
+		if !key.Valid {
+			continue
+		}
+		if err := c.media.Delete(ctx, key.String); err != nil {
+			log.Printf("Error deleting media %s: %v", key.String, err)
+		}
+	}
+	return nil
+}
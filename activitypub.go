@@ -0,0 +1,453 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/activitypub"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/pagination"
+)
+
+func (c *apiConfig) actorURL(userID uuid.UUID) string {
+	return c.baseURL + "/users/" + userID.String()
+}
+
+func (c *apiConfig) noteURL(chirpID uuid.UUID) string {
+	return c.baseURL + "/api/chirps/" + chirpID.String()
+}
+
+func (c *apiConfig) federationDomain() string {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// getActorKeys returns the user's signing keys, generating them the first
+// time the user is seen by the fediverse.
+func (c *apiConfig) getActorKeys(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	keys, err := c.dbQueries.GetActorKeys(ctx, userID)
+	if err != sql.ErrNoRows {
+		return keys, err
+	}
+	publicPEM, privatePEM, err := activitypub.GenerateKeyPair()
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	return c.dbQueries.CreateActorKeys(ctx, database.CreateActorKeysParams{
+		UserID:        userID,
+		PublicKeyPem:  publicPEM,
+		PrivateKeyPem: privatePEM,
+	})
+}
+
+func (c *apiConfig) chirpToNote(chirp database.Chirp) activitypub.Note {
+	var inReplyTo string
+	if chirp.ReplyToID.Valid {
+		inReplyTo = c.noteURL(chirp.ReplyToID.UUID)
+	}
+	return activitypub.Note{
+		ID:           c.noteURL(chirp.ID),
+		Type:         "Note",
+		AttributedTo: c.actorURL(chirp.UserID),
+		Summary:      chirp.ContentWarning.String,
+		Sensitive:    chirp.Sensitive || chirp.ContentWarning.Valid,
+		Content:      "<p>" + html.EscapeString(chirp.Body) + "</p>",
+		Published:    chirp.CreatedAt.UTC(),
+		InReplyTo:    inReplyTo,
+		URL:          c.noteURL(chirp.ID),
+		To:           []string{activitypub.PublicAddress},
+		Cc:           []string{c.actorURL(chirp.UserID) + "/followers"},
+	}
+}
+
+func (c *apiConfig) HandleWebFinger(w http.ResponseWriter, r *http.Request) {
+	user, domain, err := activitypub.ParseAcct(r.URL.Query().Get("resource"))
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if domain != c.federationDomain() {
+		respondWithError(w, "Unknown domain", http.StatusNotFound)
+		return
+	}
+	// Accounts are addressed by handle; user IDs are still accepted so
+	// remote servers that learned the older form keep resolving.
+	account, err := c.lookupUser(r.Context(), user)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+
+	respondWithContentType(w, "application/jrd+json", activitypub.NewWebFinger(account.Handle, domain, c.actorURL(account.ID)), http.StatusOK)
+}
+
+func (c *apiConfig) GetActor(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	user, err := c.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+	keys, err := c.getActorKeys(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve actor keys", http.StatusInternalServerError)
+		log.Printf("Error retrieving actor keys: %v", err)
+		return
+	}
+
+	actorURL := c.actorURL(userID)
+	actor := activitypub.Actor{
+		Context:           activitypub.Context,
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: user.Handle,
+		Inbox:             actorURL + "/inbox",
+		Outbox:            actorURL + "/outbox",
+		Followers:         actorURL + "/followers",
+		PublicKey: activitypub.PublicKey{
+			ID:           actorURL + "#main-key",
+			Owner:        actorURL,
+			PublicKeyPem: keys.PublicKeyPem,
+		},
+	}
+	respondWithContentType(w, activitypub.ContentType, actor, http.StatusOK)
+}
+
+// GetOutbox serves the user's chirps newest first. The collection itself
+// only holds the count and a link to the first page; pages are requested
+// with page=true and follow each other through cursor.
+func (c *apiConfig) GetOutbox(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	outboxURL := c.actorURL(userID) + "/outbox"
+	if r.URL.Query().Get("page") != "true" {
+		count, err := c.dbQueries.CountUserChirps(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+			log.Printf("Error counting chirps: %v", err)
+			return
+		}
+		outbox := activitypub.OrderedCollection{
+			Context:    activitypub.Context,
+			ID:         outboxURL,
+			Type:       "OrderedCollection",
+			TotalItems: int(count),
+			First:      outboxURL + "?page=true",
+		}
+		respondWithContentType(w, activitypub.ContentType, outbox, http.StatusOK)
+		return
+	}
+
+	// Pages are a fixed size so that their URLs stay canonical.
+	cursor, err := pagination.Decode(r.URL.Query().Get("cursor"))
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := database.ChirpFilter{
+		AuthorIDs:  []uuid.UUID{userID},
+		Descending: true,
+		Limit:      pagination.DefaultLimit,
+	}
+	pageURL := outboxURL + "?page=true"
+	if cursor != nil {
+		filter.Cursor = &database.ChirpCursor{Time: cursor.Time, ID: cursor.ID}
+		pageURL += "&cursor=" + cursor.Encode()
+	}
+	chirps, err := c.dbQueries.ListChirps(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		log.Printf("Error retrieving chirps: %v", err)
+		return
+	}
+
+	items := make([]any, 0, len(chirps))
+	for _, chirp := range chirps {
+		activity, err := activitypub.NewActivity(c.noteURL(chirp.ID)+"/activity", "Create", c.actorURL(userID), c.chirpToNote(chirp), []string{activitypub.PublicAddress})
+		if err != nil {
+			respondWithError(w, "Failed to build outbox", http.StatusInternalServerError)
+			log.Printf("Error building outbox activity: %v", err)
+			return
+		}
+		activity.Context = nil
+		published := chirp.CreatedAt.UTC()
+		activity.Published = &published
+		items = append(items, activity)
+	}
+
+	page := activitypub.OrderedCollectionPage{
+		Context:      activitypub.Context,
+		ID:           pageURL,
+		Type:         "OrderedCollectionPage",
+		PartOf:       outboxURL,
+		OrderedItems: items,
+	}
+	if len(chirps) == pagination.DefaultLimit {
+		last := chirps[len(chirps)-1]
+		page.Next = outboxURL + "?page=true&cursor=" + pagination.Cursor{Time: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	respondWithContentType(w, activitypub.ContentType, page, http.StatusOK)
+}
+
+func (c *apiConfig) GetFollowersCollection(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	count, err := c.dbQueries.CountRemoteFollowers(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve followers", http.StatusInternalServerError)
+		log.Printf("Error counting followers: %v", err)
+		return
+	}
+
+	// Only the count is published; individual followers stay private.
+	followers := activitypub.OrderedCollection{
+		Context:      activitypub.Context,
+		ID:           c.actorURL(userID) + "/followers",
+		Type:         "OrderedCollection",
+		TotalItems:   int(count),
+		OrderedItems: []any{},
+	}
+	respondWithContentType(w, activitypub.ContentType, followers, http.StatusOK)
+}
+
+func (c *apiConfig) HandleInbox(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := c.dbQueries.GetUserByID(r.Context(), userID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	signer, body, err := activitypub.VerifyRequest(r, activitypub.ActorKeyFetcher(c.httpClient))
+	if err != nil {
+		respondWithError(w, "Invalid signature", http.StatusUnauthorized)
+		log.Printf("Error verifying inbox signature: %v", err)
+		return
+	}
+
+	var activity activitypub.Activity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		respondWithError(w, "Failed to decode activity", http.StatusBadRequest)
+		return
+	}
+	if activity.Actor != signer {
+		respondWithError(w, "Signature does not match actor", http.StatusUnauthorized)
+		return
+	}
+
+	switch activity.Type {
+	case "Follow":
+		err = c.handleFollowActivity(r.Context(), userID, activity)
+	case "Undo":
+		if activity.ObjectType() == "Follow" {
+			err = c.dbQueries.RemoveRemoteFollower(r.Context(), database.RemoveRemoteFollowerParams{
+				UserID:  userID,
+				ActorID: activity.Actor,
+			})
+		}
+	case "Create":
+		err = c.handleCreateActivity(r.Context(), activity)
+	case "Delete":
+		err = c.dbQueries.DeleteRemotePost(r.Context(), database.DeleteRemotePostParams{
+			ApID:    activity.ObjectID(),
+			ActorID: activity.Actor,
+		})
+	}
+	if err != nil {
+		if err == errInvalidActivity {
+			respondWithError(w, "Invalid activity", http.StatusBadRequest)
+			return
+		}
+		respondWithError(w, "Failed to process activity", http.StatusInternalServerError)
+		log.Printf("Error processing %s activity: %v", activity.Type, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+var errInvalidActivity = errors.New("invalid activity")
+
+func (c *apiConfig) handleFollowActivity(ctx context.Context, userID uuid.UUID, follow activitypub.Activity) error {
+	if follow.ObjectID() != c.actorURL(userID) {
+		return errInvalidActivity
+	}
+	remote, err := activitypub.FetchActor(ctx, c.httpClient, follow.Actor)
+	if err != nil {
+		return err
+	}
+	err = c.dbQueries.AddRemoteFollower(ctx, database.AddRemoteFollowerParams{
+		UserID:  userID,
+		ActorID: remote.ID,
+		Inbox:   remote.Inbox,
+	})
+	if err != nil {
+		return err
+	}
+
+	accept, err := activitypub.NewActivity(c.actorURL(userID)+"#accepts/"+uuid.NewString(), "Accept", c.actorURL(userID), follow, nil)
+	if err != nil {
+		return err
+	}
+	return c.deliverActivity(ctx, userID, accept, []string{remote.Inbox})
+}
+
+func (c *apiConfig) handleCreateActivity(ctx context.Context, create activitypub.Activity) error {
+	if create.ObjectType() != "Note" {
+		return nil
+	}
+	var note activitypub.Note
+	if err := json.Unmarshal(create.Object, &note); err != nil {
+		return errInvalidActivity
+	}
+	if note.ID == "" || note.AttributedTo != create.Actor {
+		return errInvalidActivity
+	}
+	return c.dbQueries.CreateRemotePost(ctx, database.CreateRemotePostParams{
+		ApID:        note.ID,
+		ActorID:     create.Actor,
+		Content:     note.Content,
+		InReplyTo:   sql.NullString{String: note.InReplyTo, Valid: note.InReplyTo != ""},
+		PublishedAt: note.Published,
+	})
+}
+
+func (c *apiConfig) deliverActivity(ctx context.Context, userID uuid.UUID, activity activitypub.Activity, inboxes []string) error {
+	if len(inboxes) == 0 {
+		return nil
+	}
+	keys, err := c.getActorKeys(ctx, userID)
+	if err != nil {
+		return err
+	}
+	deliveries, err := c.signedDeliveries(userID, keys, activity, inboxes)
+	if err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		c.federation.Enqueue(d)
+	}
+	return nil
+}
+
+// signedDeliveries addresses activity to each inbox, to be signed with the
+// user's key.
+func (c *apiConfig) signedDeliveries(userID uuid.UUID, keys database.ActorKey, activity activitypub.Activity, inboxes []string) ([]activitypub.Delivery, error) {
+	privateKey, err := activitypub.ParsePrivateKey(keys.PrivateKeyPem)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]activitypub.Delivery, 0, len(inboxes))
+	for _, inbox := range inboxes {
+		deliveries = append(deliveries, activitypub.Delivery{
+			Inbox:      inbox,
+			Body:       body,
+			KeyID:      c.actorURL(userID) + "#main-key",
+			PrivateKey: privateKey,
+		})
+	}
+	return deliveries, nil
+}
+
+// accountDeleteDeliveries prepares a Delete of the user's actor, which tells
+// remote servers to remove the account and every note it wrote. It has to
+// run before the user is deleted, because their keys and remote followers
+// are deleted with them.
+func (c *apiConfig) accountDeleteDeliveries(ctx context.Context, q *database.Queries, userID uuid.UUID) ([]activitypub.Delivery, error) {
+	inboxes, err := q.GetRemoteFollowerInboxes(ctx, userID)
+	if err != nil || len(inboxes) == 0 {
+		return nil, err
+	}
+	keys, err := q.GetActorKeys(ctx, userID)
+	if err == sql.ErrNoRows {
+		// Never signed anything, so no remote server can verify a Delete.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	actor := c.actorURL(userID)
+	activity, err := activitypub.NewActivity(actor+"#delete", "Delete", actor, actor, []string{activitypub.PublicAddress})
+	if err != nil {
+		return nil, err
+	}
+	return c.signedDeliveries(userID, keys, activity, inboxes)
+}
+
+// federateChirp sends a Create or Delete for the chirp to every remote
+// follower of its author. Failures are logged rather than surfaced because
+// the local operation has already succeeded.
+func (c *apiConfig) federateChirp(ctx context.Context, chirp database.Chirp, activityType string) {
+	inboxes, err := c.dbQueries.GetRemoteFollowerInboxes(ctx, chirp.UserID)
+	if err != nil {
+		log.Printf("Error retrieving remote followers: %v", err)
+		return
+	}
+	if len(inboxes) == 0 {
+		return
+	}
+
+	var object any = c.chirpToNote(chirp)
+	if activityType == "Delete" {
+		object = map[string]string{"id": c.noteURL(chirp.ID), "type": "Tombstone"}
+	}
+	id := c.noteURL(chirp.ID) + "/" + strings.ToLower(activityType)
+	// A chirp can be updated more than once, and remote servers drop an
+	// activity whose ID they have already seen.
+	if activityType == "Update" {
+		id += "/" + strconv.FormatInt(chirp.UpdatedAt.UnixMicro(), 10)
+	}
+	activity, err := activitypub.NewActivity(id, activityType, c.actorURL(chirp.UserID), object, []string{activitypub.PublicAddress})
+	if err != nil {
+		log.Printf("Error building %s activity: %v", activityType, err)
+		return
+	}
+	if err := c.deliverActivity(ctx, chirp.UserID, activity, inboxes); err != nil {
+		log.Printf("Error delivering %s activity: %v", activityType, err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/analytics"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/pagination"
+)
+
+const (
+	defaultAnalyticsDays = 30
+	maxAnalyticsDays     = 365
+)
+
+// recordViews counts an impression of each chirp served to the viewer.
+// Authors looking at their own chirps don't inflate their reach.
+func (c *apiConfig) recordViews(viewerID uuid.UUID, chirps ...database.Chirp) {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		if chirp.UserID != viewerID {
+			ids = append(ids, chirp.ID)
+		}
+	}
+	c.views.Record(ids...)
+}
+
+func (c *apiConfig) flushViews(ctx context.Context, counts map[analytics.ViewKey]int64) error {
+	params := database.AddChirpViewsParams{}
+	for key, views := range counts {
+		params.ChirpIds = append(params.ChirpIds, key.ChirpID)
+		params.Days = append(params.Days, key.Day)
+		params.Views = append(params.Views, views)
+	}
+	return c.dbQueries.AddChirpViews(ctx, params)
+}
+
+func (c *apiConfig) GetAnalytics(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days := defaultAnalyticsDays
+	if s := r.URL.Query().Get("days"); s != "" {
+		days, err = strconv.Atoi(s)
+		if err != nil || days < 1 || days > maxAnalyticsDays {
+			respondWithError(w, "days must be between 1 and 365", http.StatusBadRequest)
+			return
+		}
+	}
+
+	beforeTime, beforeID := cursorParams(cursor)
+	rows, err := c.dbQueries.GetAuthorChirpStats(r.Context(), database.GetAuthorChirpStatsParams{
+		UserID:     userID,
+		BeforeTime: beforeTime,
+		BeforeID:   beforeID,
+		Limit:      limit,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to retrieve analytics", http.StatusInternalServerError)
+		log.Printf("Error retrieving chirp stats: %v", err)
+		return
+	}
+	since := time.Now().UTC().AddDate(0, 0, 1-days)
+	daily, err := c.dbQueries.GetAuthorDailyStats(r.Context(), database.GetAuthorDailyStatsParams{
+		UserID: userID,
+		Since:  since,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to retrieve analytics", http.StatusInternalServerError)
+		log.Printf("Error retrieving daily stats: %v", err)
+		return
+	}
+
+	response := analyticsResponse{Chirps: []chirpStats{}, Daily: []dailyStats{}}
+	for _, row := range rows {
+		response.Chirps = append(response.Chirps, chirpStats{
+			ChirpID:   row.ID,
+			CreatedAt: row.CreatedAt,
+			Views:     row.Views,
+			Likes:     row.Likes,
+			Replies:   row.Replies,
+		})
+	}
+	for _, row := range daily {
+		response.Daily = append(response.Daily, dailyStats{
+			Date:    row.Day.Format(time.DateOnly),
+			Views:   row.Views,
+			Likes:   row.Likes,
+			Replies: row.Replies,
+		})
+	}
+	if len(rows) == int(limit) {
+		last := rows[len(rows)-1]
+		response.NextCursor = pagination.Cursor{Time: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	respondWithJSON(w, response, http.StatusOK)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+// Actions recorded in the audit log.
+const (
+	auditSuspend           = "suspend"
+	auditUnsuspend         = "unsuspend"
+	auditSetRole           = "set_role"
+	auditSetContentWarning = "set_content_warning"
+)
+
+// actor is the audit log's actor_id for a change made by user.
+func actor(user uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: user, Valid: true}
+}
+
+// recordAudit adds an entry to the audit log. Callers pass the queries of
+// the transaction making the change, so the entry is kept only if the
+// change is.
+func recordAudit(ctx context.Context, q *database.Queries, entry database.CreateAuditEntryParams, details any) error {
+	var err error
+	entry.Details, err = json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	return q.CreateAuditEntry(ctx, entry)
+}
@@ -3,13 +3,20 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/tbirddv/chirpy/internal/auth"
 	"github.com/tbirddv/chirpy/internal/database"
 )
 
+// refreshReuseGrace is how long after a rotation the replaced refresh token
+// is still answered with its successor, so a client refreshing from two
+// tabs at once isn't logged out for reuse.
+const refreshReuseGrace = 20 * time.Second
+
 func (c *apiConfig) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	var p userParams
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
@@ -33,82 +40,187 @@ func (c *apiConfig) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, c.tokenSecret, time.Hour)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if isSuspended(user) {
+		http.Error(w, suspensionMessage(user), http.StatusForbidden)
 		return
 	}
 
-	refreshToken, err := auth.MakeRefreshToken()
+	// Logging in during the grace period restores a deleted account.
+	if user.DeletionScheduledAt.Valid {
+		if err := c.dbQueries.CancelUserDeletion(r.Context(), user.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Each login starts a new session, which is a new refresh token family.
+	sessionID := uuid.New()
+	token, err := auth.MakeJWT(user.ID, auth.Role(user.Role), sessionID, c.tokenSecret, time.Hour)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	_, err = c.dbQueries.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token:     refreshToken,
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(60 * 24 * time.Hour), // 60 days
-	})
+	refreshToken, err := issueRefreshToken(r, c.dbQueries, user.ID, sessionID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	loginResponse := loginResponse{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		IsChirpyRed:  user.IsChirpyRed,
-		AccessToken:  token,
-		RefreshToken: refreshToken,
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Handle:        user.Handle,
+		IsChirpyRed:   user.IsChirpyRed,
+		Role:          user.Role,
+		AccessToken:   token,
+		RefreshToken:  refreshToken,
 	}
 
 	respondWithJSON(w, loginResponse, http.StatusOK)
 }
 
+// issueRefreshToken creates a refresh token in the given family, recording
+// the client that asked for it.
+func issueRefreshToken(r *http.Request, q *database.Queries, userID, familyID uuid.UUID) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, saveRefreshToken(r, q, refreshToken, userID, familyID)
+}
+
+func saveRefreshToken(r *http.Request, q *database.Queries, refreshToken string, userID, familyID uuid.UUID) error {
+	_, err := q.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		Token:     refreshToken,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(60 * 24 * time.Hour), // 60 days
+		FamilyID:  familyID,
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		IpAddress: clientIP(r),
+	})
+	return err
+}
+
+// HandleRefresh swaps a refresh token for a new access token and a new
+// refresh token in the same family, revoking the old one. Presenting a
+// token that has already been revoked means it was copied, so the whole
+// family is revoked and whoever holds the newest token must log in again.
+// A client that sends the same token twice at once is not a thief, though:
+// for refreshReuseGrace after a rotation, the replaced token is answered
+// with the successor it was already given.
 func (c *apiConfig) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	tokenData, err := c.dbQueries.GetRefreshToken(r.Context(), refreshToken)
+	tx, err := c.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
-			return
-		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
 
-	if tokenData.RevokedAt.Valid || tokenData.ExpiresAt.Before(time.Now()) {
-		http.Error(w, "Refresh token expired or revoked", http.StatusUnauthorized)
+	old, err := qtx.RotateRefreshToken(r.Context(), database.RotateRefreshTokenParams{
+		Token:      refreshToken,
+		ReplacedBy: sql.NullString{String: newRefreshToken, Valid: true},
+	})
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		c.rejectRefreshToken(w, r, refreshToken)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	user, err := c.dbQueries.GetUserByRefreshToken(r.Context(), refreshToken)
+	user, err := c.checkActiveUser(r.Context(), old.UserID)
 	if err != nil {
+		http.Error(w, "Account is not active", http.StatusUnauthorized)
+		return
+	}
+	if err := saveRefreshToken(r, qtx, newRefreshToken, user.ID, old.FamilyID); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.respondWithRefresh(w, user, old.FamilyID, newRefreshToken)
+}
 
-	newAccessToken, err := auth.MakeJWT(user.ID, c.tokenSecret, time.Hour)
+func (c *apiConfig) respondWithRefresh(w http.ResponseWriter, user database.User, familyID uuid.UUID, refreshToken string) {
+	newAccessToken, err := auth.MakeJWT(user.ID, auth.Role(user.Role), familyID, c.tokenSecret, time.Hour)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	response := refreshResponse{
-		Token: newAccessToken,
+		Token:        newAccessToken,
+		RefreshToken: refreshToken,
 	}
 
 	respondWithJSON(w, response, http.StatusOK)
 }
 
+// rejectRefreshToken answers a refresh with a token that could not be
+// rotated. A token rotated within refreshReuseGrace gets its successor,
+// provided that is still live; one revoked longer ago has been copied, so
+// its family is revoked.
+func (c *apiConfig) rejectRefreshToken(w http.ResponseWriter, r *http.Request, refreshToken string) {
+	tokenData, err := c.dbQueries.GetRefreshToken(r.Context(), refreshToken)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !tokenData.RevokedAt.Valid {
+		http.Error(w, "Refresh token expired or revoked", http.StatusUnauthorized)
+		return
+	}
+	if tokenData.ReplacedBy.Valid && time.Since(tokenData.RevokedAt.Time) <= refreshReuseGrace {
+		successor, err := c.dbQueries.GetRefreshToken(r.Context(), tokenData.ReplacedBy.String)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if successor.RevokedAt.Valid || !successor.ExpiresAt.After(time.Now()) {
+			http.Error(w, "Refresh token expired or revoked", http.StatusUnauthorized)
+			return
+		}
+		user, err := c.checkActiveUser(r.Context(), successor.UserID)
+		if err != nil {
+			http.Error(w, "Account is not active", http.StatusUnauthorized)
+			return
+		}
+		c.respondWithRefresh(w, user, successor.FamilyID, successor.Token)
+		return
+	}
+	if err := c.dbQueries.RevokeRefreshTokenFamily(r.Context(), tokenData.FamilyID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Revoked refresh token family %s of user %s after reuse", tokenData.FamilyID, tokenData.UserID)
+	http.Error(w, "Refresh token expired or revoked", http.StatusUnauthorized)
+}
+
 func (c *apiConfig) HandleRevoke(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/analytics"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/database/dbtest"
+	"github.com/tbirddv/chirpy/internal/password"
+)
+
+// testConfig connects to the migrated database in TEST_DB_URL. Tests that
+// need a real database are skipped without one.
+func testConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	db := dbtest.Open(t)
+	views := analytics.NewViewCounter(func(context.Context, map[analytics.ViewKey]int64) error { return nil }, analytics.Options{})
+	t.Cleanup(func() { views.Close() })
+	return &apiConfig{db: db, dbQueries: database.New(db), tokenSecret: "test-secret", views: views, passwordPolicy: password.DefaultPolicy()}
+}
+
+func refresh(c *apiConfig, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	c.HandleRefresh(rec, req)
+	return rec
+}
+
+// refreshConcurrently presents token in several requests at once.
+func refreshConcurrently(c *apiConfig, token string, n int) []*httptest.ResponseRecorder {
+	recs := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := range recs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = refresh(c, token)
+		}()
+	}
+	wg.Wait()
+	return recs
+}
+
+func startSession(t *testing.T, c *apiConfig) (database.User, uuid.UUID, string) {
+	t.Helper()
+	user := dbtest.CreateUser(t, c.dbQueries)
+	familyID := uuid.New()
+	token, err := issueRefreshToken(httptest.NewRequest(http.MethodPost, "/api/login", nil), c.dbQueries, user.ID, familyID)
+	if err != nil {
+		t.Fatalf("Failed to issue refresh token: %v", err)
+	}
+	return user, familyID, token
+}
+
+func sessionActive(t *testing.T, c *apiConfig, userID, familyID uuid.UUID) bool {
+	t.Helper()
+	active, err := c.dbQueries.IsSessionActive(context.Background(), database.IsSessionActiveParams{
+		FamilyID: familyID,
+		UserID:   userID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to check session: %v", err)
+	}
+	return active
+}
+
+func TestConcurrentRefreshesShareSuccessor(t *testing.T) {
+	c := testConfig(t)
+	user, familyID, token := startSession(t, c)
+
+	recs := refreshConcurrently(c, token, 4)
+	var successor string
+	for _, rec := range recs {
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected every racing refresh to succeed, got %d: %s", rec.Code, rec.Body)
+		}
+		var resp refreshResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode refresh response: %v", err)
+		}
+		if successor == "" {
+			successor = resp.RefreshToken
+		} else if resp.RefreshToken != successor {
+			t.Errorf("Expected one successor, got %s and %s", successor, resp.RefreshToken)
+		}
+	}
+	if !sessionActive(t, c, user.ID, familyID) {
+		t.Errorf("Expected racing refreshes to leave the session active")
+	}
+	if rec := refresh(c, successor); rec.Code != http.StatusOK {
+		t.Errorf("Expected the shared successor to refresh, got %d", rec.Code)
+	}
+}
+
+func TestRefreshReuseAfterGraceRevokesFamily(t *testing.T) {
+	c := testConfig(t)
+	user, familyID, token := startSession(t, c)
+
+	if rec := refresh(c, token); rec.Code != http.StatusOK {
+		t.Fatalf("Expected first refresh to succeed, got %d", rec.Code)
+	}
+	_, err := c.db.Exec(`UPDATE refresh_tokens SET revoked_at = revoked_at - $2 * INTERVAL '1 second' WHERE token = $1`,
+		token, int(refreshReuseGrace.Seconds())+1)
+	if err != nil {
+		t.Fatalf("Failed to age rotation: %v", err)
+	}
+
+	for _, rec := range refreshConcurrently(c, token, 2) {
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected reuse after the grace period to be rejected, got %d", rec.Code)
+		}
+	}
+	if sessionActive(t, c, user.ID, familyID) {
+		t.Errorf("Expected reuse after the grace period to revoke the session")
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+// getViewer returns the logged in user for endpoints that also serve
+// anonymous readers. uuid.Nil means no Authorization header was sent; an
+// invalid token is still an error.
+func (c *apiConfig) getViewer(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+	return c.getLoggedInUser(r)
+}
+
+// hiddenAuthors returns the set of users whose chirps the viewer must not
+// see because of a block in either direction or a mute.
+func (c *apiConfig) hiddenAuthors(ctx context.Context, viewerID uuid.UUID) (map[uuid.UUID]struct{}, error) {
+	hidden := make(map[uuid.UUID]struct{})
+	if viewerID == uuid.Nil {
+		return hidden, nil
+	}
+	ids, err := c.dbQueries.GetHiddenUserIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		hidden[id] = struct{}{}
+	}
+	return hidden, nil
+}
+
+// hiddenVersions counts changes to each user's blocks and mutes, so that
+// open streams can tell when the authors they hide need reloading. A block
+// changes what both users may see; a mute only the muter's.
+type hiddenVersions struct {
+	m sync.Map // uuid.UUID to *atomic.Uint64
+}
+
+func (v *hiddenVersions) get(userID uuid.UUID) uint64 {
+	if n, ok := v.m.Load(userID); ok {
+		return n.(*atomic.Uint64).Load()
+	}
+	return 0
+}
+
+func (v *hiddenVersions) bump(userIDs ...uuid.UUID) {
+	for _, id := range userIDs {
+		n, _ := v.m.LoadOrStore(id, new(atomic.Uint64))
+		n.(*atomic.Uint64).Add(1)
+	}
+}
+
+// hiddenTracker is hiddenAuthors for a long-lived stream: it reloads the
+// set whenever the viewer's blocks or mutes have changed since it was read.
+type hiddenTracker struct {
+	c       *apiConfig
+	viewer  uuid.UUID
+	version uint64
+	authors map[uuid.UUID]struct{}
+}
+
+func (c *apiConfig) trackHidden(ctx context.Context, viewerID uuid.UUID) (*hiddenTracker, error) {
+	t := &hiddenTracker{c: c, viewer: viewerID}
+	return t, t.reload(ctx)
+}
+
+func (t *hiddenTracker) reload(ctx context.Context) error {
+	// Read the version first: a change landing during the load then shows
+	// up as a new version and is picked up next time.
+	version := t.c.hiddenVersions.get(t.viewer)
+	authors, err := t.c.hiddenAuthors(ctx, t.viewer)
+	if err != nil {
+		return err
+	}
+	t.version, t.authors = version, authors
+	return nil
+}
+
+// hides reports whether the viewer must not see events from authorID.
+func (t *hiddenTracker) hides(ctx context.Context, authorID uuid.UUID) (bool, error) {
+	if t.c.hiddenVersions.get(t.viewer) != t.version {
+		if err := t.reload(ctx); err != nil {
+			return false, err
+		}
+	}
+	_, hidden := t.authors[authorID]
+	return hidden, nil
+}
+
+func (c *apiConfig) isBlockedEitherWay(ctx context.Context, a, b uuid.UUID) (bool, error) {
+	return c.dbQueries.IsBlockedEitherWay(ctx, database.IsBlockedEitherWayParams{BlockerID: a, BlockedID: b})
+}
+
+func decodeTargetUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (uuid.UUID, bool) {
+	var p relationshipParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, "Failed to decode request", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	if p.UserID == uuid.Nil {
+		respondWithError(w, "user_id is required", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	if p.UserID == userID {
+		respondWithError(w, "You cannot do that to yourself", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return p.UserID, true
+}
+
+func (c *apiConfig) BlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	targetID, ok := decodeTargetUser(w, r, userID)
+	if !ok {
+		return
+	}
+	if _, err := c.dbQueries.GetUserByID(r.Context(), targetID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+
+	// Blocking also severs follows in both directions, atomically with the
+	// block itself.
+	tx, err := c.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, "Failed to block user", http.StatusInternalServerError)
+		log.Printf("Error starting transaction: %v", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
+
+	if err := qtx.BlockUser(r.Context(), database.BlockUserParams{BlockerID: userID, BlockedID: targetID}); err != nil {
+		respondWithError(w, "Failed to block user", http.StatusInternalServerError)
+		log.Printf("Error blocking user: %v", err)
+		return
+	}
+	if err := qtx.RemoveFollowsBetween(r.Context(), database.RemoveFollowsBetweenParams{FollowerID: userID, FolloweeID: targetID}); err != nil {
+		respondWithError(w, "Failed to block user", http.StatusInternalServerError)
+		log.Printf("Error removing follows: %v", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, "Failed to block user", http.StatusInternalServerError)
+		log.Printf("Error committing block: %v", err)
+		return
+	}
+	c.hiddenVersions.bump(userID, targetID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *apiConfig) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.dbQueries.UnblockUser(r.Context(), database.UnblockUserParams{BlockerID: userID, BlockedID: targetID}); err != nil {
+		respondWithError(w, "Failed to unblock user", http.StatusInternalServerError)
+		log.Printf("Error unblocking user: %v", err)
+		return
+	}
+	c.hiddenVersions.bump(userID, targetID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *apiConfig) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	blocks, err := c.dbQueries.GetBlocks(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve blocks", http.StatusInternalServerError)
+		log.Printf("Error retrieving blocks: %v", err)
+		return
+	}
+
+	entries := make([]relationshipEntry, 0, len(blocks))
+	for _, block := range blocks {
+		entries = append(entries, relationshipEntry{UserID: block.BlockedID, CreatedAt: block.CreatedAt})
+	}
+	respondWithJSON(w, entries, http.StatusOK)
+}
+
+func (c *apiConfig) MuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	targetID, ok := decodeTargetUser(w, r, userID)
+	if !ok {
+		return
+	}
+	if _, err := c.dbQueries.GetUserByID(r.Context(), targetID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+
+	if err := c.dbQueries.MuteUser(r.Context(), database.MuteUserParams{MuterID: userID, MutedID: targetID}); err != nil {
+		respondWithError(w, "Failed to mute user", http.StatusInternalServerError)
+		log.Printf("Error muting user: %v", err)
+		return
+	}
+	c.hiddenVersions.bump(userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *apiConfig) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.dbQueries.UnmuteUser(r.Context(), database.UnmuteUserParams{MuterID: userID, MutedID: targetID}); err != nil {
+		respondWithError(w, "Failed to unmute user", http.StatusInternalServerError)
+		log.Printf("Error unmuting user: %v", err)
+		return
+	}
+	c.hiddenVersions.bump(userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *apiConfig) GetMutes(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	mutes, err := c.dbQueries.GetMutes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve mutes", http.StatusInternalServerError)
+		log.Printf("Error retrieving mutes: %v", err)
+		return
+	}
+
+	entries := make([]relationshipEntry, 0, len(mutes))
+	for _, mute := range mutes {
+		entries = append(entries, relationshipEntry{UserID: mute.MutedID, CreatedAt: mute.CreatedAt})
+	}
+	respondWithJSON(w, entries, http.StatusOK)
+}
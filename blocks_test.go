@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/database/dbtest"
+)
+
+func TestHiddenTrackerSeesNewBlocks(t *testing.T) {
+	c := testConfig(t)
+	ctx := context.Background()
+	viewer := dbtest.CreateUser(t, c.dbQueries)
+	author := dbtest.CreateUser(t, c.dbQueries)
+
+	hidden, err := c.trackHidden(ctx, viewer.ID)
+	if err != nil {
+		t.Fatalf("Failed to load hidden authors: %v", err)
+	}
+	if isHidden, err := hidden.hides(ctx, author.ID); err != nil || isHidden {
+		t.Fatalf("Expected the author to be visible before the block, got %v, %v", isHidden, err)
+	}
+
+	// The author blocks the viewer, which hides them from each other.
+	if err := c.dbQueries.BlockUser(ctx, database.BlockUserParams{BlockerID: author.ID, BlockedID: viewer.ID}); err != nil {
+		t.Fatalf("Failed to block: %v", err)
+	}
+	c.hiddenVersions.bump(author.ID, viewer.ID)
+	if isHidden, err := hidden.hides(ctx, author.ID); err != nil || !isHidden {
+		t.Errorf("Expected the block to hide the author from an open stream, got %v, %v", isHidden, err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/handle"
+	"github.com/tbirddv/chirpy/internal/pagination"
+)
+
+// parseChirpFilter turns the GET /api/chirps query string into a filter. It
+// checks every parameter and reports all problems at once, keyed by
+// parameter name.
+func parseChirpFilter(query url.Values) (database.ChirpFilter, map[string]string) {
+	var filter database.ChirpFilter
+	fieldErrors := make(map[string]string)
+
+	for _, value := range query["author_id"] {
+		for _, id := range strings.Split(value, ",") {
+			authorID, err := uuid.Parse(strings.TrimSpace(id))
+			if err != nil {
+				fieldErrors["author_id"] = "must be one or more user IDs"
+				break
+			}
+			filter.AuthorIDs = append(filter.AuthorIDs, authorID)
+		}
+	}
+	// author takes handles as well as IDs.
+	for _, value := range query["author"] {
+		for _, author := range strings.Split(value, ",") {
+			author = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(author), "@"))
+			if authorID, err := uuid.Parse(author); err == nil {
+				filter.AuthorIDs = append(filter.AuthorIDs, authorID)
+				continue
+			}
+			if !handle.Valid(author) {
+				fieldErrors["author"] = "must be one or more handles or user IDs"
+				break
+			}
+			filter.AuthorHandles = append(filter.AuthorHandles, handle.Normalize(author))
+		}
+	}
+
+	parseTime := func(name string) *time.Time {
+		value := query.Get(name)
+		if value == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			fieldErrors[name] = "must be an RFC 3339 timestamp"
+			return nil
+		}
+		return &t
+	}
+	filter.Since = parseTime("since")
+	filter.Until = parseTime("until")
+	if filter.Since != nil && filter.Until != nil && !filter.Until.After(*filter.Since) {
+		fieldErrors["until"] = "must be after since"
+	}
+
+	parseID := func(name string) *uuid.UUID {
+		value := query.Get(name)
+		if value == "" {
+			return nil
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			fieldErrors[name] = "must be a chirp ID"
+			return nil
+		}
+		return &id
+	}
+	filter.SinceID = parseID("since_id")
+	filter.MaxID = parseID("max_id")
+
+	filter.Contains = strings.TrimSpace(query.Get("contains"))
+	if len(filter.Contains) > 140 {
+		fieldErrors["contains"] = "must be at most 140 characters"
+	}
+
+	if value := query.Get("is_reply"); value != "" {
+		isReply, err := strconv.ParseBool(value)
+		if err != nil {
+			fieldErrors["is_reply"] = "must be true or false"
+		} else {
+			filter.IsReply = &isReply
+		}
+	}
+
+	switch query.Get("sort") {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	case "top":
+		filter.Rank = database.RankTop
+	case "hot":
+		filter.Rank = database.RankHot
+	default:
+		fieldErrors["sort"] = "must be asc, desc, top or hot"
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := decodeChirpCursor(value, filter.Rank)
+		if err != nil {
+			fieldErrors["cursor"] = "must be a cursor from a previous page"
+		}
+		filter.Cursor = cursor
+	}
+
+	limit, err := pagination.ParseLimit(query.Get("limit"))
+	if err != nil {
+		fieldErrors["limit"] = "must be a positive integer"
+	}
+	filter.Limit = limit
+
+	return filter, fieldErrors
+}
+
+// nextChirpsPage returns query with its cursor moved past last. Ranked
+// cursors hold last's score as it was on this page, so the next page picks
+// up from the same place even if last has been liked or deleted since.
+func nextChirpsPage(query url.Values, rank database.ChirpRank, last database.Chirp) url.Values {
+	var cursor string
+	switch rank {
+	case database.RankTop:
+		cursor = pagination.ScoreCursor{Score: float64(last.TopScore), ID: last.ID}.Encode()
+	case database.RankHot:
+		cursor = pagination.ScoreCursor{Score: last.HotScore, ID: last.ID}.Encode()
+	default:
+		cursor = pagination.Cursor{Time: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	query.Set("cursor", cursor)
+	return query
+}
+
+// decodeChirpCursor reads a cursor written by nextChirpsPage for the same
+// sort order.
+func decodeChirpCursor(value string, rank database.ChirpRank) (*database.ChirpCursor, error) {
+	if rank != "" {
+		cursor, err := pagination.DecodeScore(value)
+		if err != nil {
+			return nil, err
+		}
+		return &database.ChirpCursor{Score: cursor.Score, ID: cursor.ID}, nil
+	}
+	cursor, err := pagination.Decode(value)
+	if err != nil {
+		return nil, err
+	}
+	return &database.ChirpCursor{Time: cursor.Time, ID: cursor.ID}, nil
+}
+
+// checkCursorChirps reports since_id and max_id chirps that don't exist.
+// Comparing against a missing chirp is NULL for every row, which would
+// silently list nothing.
+func (c *apiConfig) checkCursorChirps(ctx context.Context, filter database.ChirpFilter) (map[string]string, error) {
+	fieldErrors := make(map[string]string)
+	for name, id := range map[string]*uuid.UUID{"since_id": filter.SinceID, "max_id": filter.MaxID} {
+		if id == nil {
+			continue
+		}
+		_, err := c.dbQueries.GetChirpByID(ctx, *id)
+		if err == sql.ErrNoRows {
+			fieldErrors[name] = "no chirp with this ID"
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fieldErrors, nil
+}
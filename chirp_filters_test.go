@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/database/dbtest"
+	"github.com/tbirddv/chirpy/internal/pagination"
+)
+
+func TestChirpFilterLimit(t *testing.T) {
+	tests := map[string]int32{"": pagination.DefaultLimit, "5": 5, "1000": pagination.MaxLimit}
+	for input, want := range tests {
+		filter, fieldErrors := parseChirpFilter(url.Values{"limit": {input}})
+		if len(fieldErrors) > 0 || filter.Limit != want {
+			t.Errorf("limit=%q gave %d, %v; want %d", input, filter.Limit, fieldErrors, want)
+		}
+	}
+	if _, fieldErrors := parseChirpFilter(url.Values{"limit": {"0"}}); fieldErrors["limit"] == "" {
+		t.Errorf("Expected limit=0 to be rejected")
+	}
+}
+
+func TestGetChirpsRejectsMissingCursor(t *testing.T) {
+	c := testConfig(t)
+	user := dbtest.CreateUser(t, c.dbQueries)
+	chirp, err := c.dbQueries.CreateChirp(context.Background(), database.CreateChirpParams{UserID: user.ID, Body: "cursor"})
+	if err != nil {
+		t.Fatalf("Failed to create chirp: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	c.GetChirps(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?since_id="+chirp.ID.String(), nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected an existing cursor to be accepted, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	c.GetChirps(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?max_id="+uuid.NewString(), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected a missing cursor to be rejected, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestChirpFilterCursor(t *testing.T) {
+	cursor := pagination.Cursor{Time: time.Now().UTC().Truncate(time.Microsecond), ID: uuid.New()}
+	filter, fieldErrors := parseChirpFilter(url.Values{"cursor": {cursor.Encode()}})
+	if len(fieldErrors) > 0 || filter.Cursor == nil || filter.Cursor.ID != cursor.ID || !filter.Cursor.Time.Equal(cursor.Time) {
+		t.Errorf("Expected the cursor to be decoded, got %+v, %v", filter.Cursor, fieldErrors)
+	}
+	if _, fieldErrors := parseChirpFilter(url.Values{"cursor": {"not a cursor"}}); fieldErrors["cursor"] == "" {
+		t.Errorf("Expected an invalid cursor to be rejected")
+	}
+
+	last := database.Chirp{ID: uuid.New(), HotScore: 1234.5678}
+	next := nextChirpsPage(url.Values{"sort": {"hot"}}, database.RankHot, last)
+	filter, fieldErrors = parseChirpFilter(next)
+	if len(fieldErrors) > 0 || filter.Cursor == nil || filter.Cursor.Score != last.HotScore || filter.Cursor.ID != last.ID {
+		t.Errorf("Expected the hot cursor to carry the last score, got %+v, %v", filter.Cursor, fieldErrors)
+	}
+}
+
+func TestGetChirpsPages(t *testing.T) {
+	c := testConfig(t)
+	user := dbtest.CreateUser(t, c.dbQueries)
+	var ids []uuid.UUID
+	for _, body := range []string{"first", "second"} {
+		chirp, err := c.dbQueries.CreateChirp(context.Background(), database.CreateChirpParams{UserID: user.ID, Body: body})
+		if err != nil {
+			t.Fatalf("Failed to create chirp: %v", err)
+		}
+		ids = append(ids, chirp.ID)
+	}
+
+	list := func(target string) ([]Chirp, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		c.GetChirps(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d: %s", target, rec.Code, rec.Body)
+		}
+		var chirps []Chirp
+		if err := json.NewDecoder(rec.Body).Decode(&chirps); err != nil {
+			t.Fatalf("Failed to decode chirps: %v", err)
+		}
+		return chirps, rec.Header().Get("Link")
+	}
+
+	chirps, link := list("/api/chirps?limit=1&author_id=" + user.ID.String())
+	if len(chirps) != 1 || chirps[0].ID != ids[0] {
+		t.Fatalf("Expected the first chirp, got %+v", chirps)
+	}
+	next, ok := strings.CutPrefix(link, "<")
+	next, _, found := strings.Cut(next, `>; rel="next"`)
+	if !ok || !found {
+		t.Fatalf("Expected a next link, got %q", link)
+	}
+	chirps, _ = list(next)
+	if len(chirps) != 1 || chirps[0].ID != ids[1] {
+		t.Errorf("Expected the next page to hold the second chirp, got %+v", chirps)
+	}
+
+	chirps, _ = list("/api/chirps?author_id=" + user.ID.String() + "&max_id=" + ids[1].String())
+	if len(chirps) != 1 || chirps[0].ID != ids[0] {
+		t.Errorf("Expected max_id to leave its own chirp out, got %+v", chirps)
+	}
+}
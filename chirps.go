@@ -1,17 +1,54 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/conditional"
 	"github.com/tbirddv/chirpy/internal/database"
 )
 
+const (
+	maxContentWarningLength = 100
+	minChirpLifetime        = 60
+	maxChirpLifetime        = 30 * 24 * 60 * 60
+)
+
+var profaneWords = []string{"kerfuffle", "sharbert", "fornax"}
+
+// contentWarning trims a content warning and filters it like a chirp body.
+// An empty warning means the chirp has none.
+func contentWarning(w http.ResponseWriter, cw string) (sql.NullString, bool) {
+	cw = strings.TrimSpace(cw)
+	if cw == "" {
+		return sql.NullString{}, true
+	}
+	if len(cw) > maxContentWarningLength {
+		respondWithError(w, "Content warning is too long", http.StatusBadRequest)
+		return sql.NullString{}, false
+	}
+	return sql.NullString{String: cleanProfanity(cw, profaneWords), Valid: true}, true
+}
+
+// hidesSensitive reports whether the viewer has asked for chirps with a
+// content warning or the sensitive flag to be left out of listings.
+func (c *apiConfig) hidesSensitive(ctx context.Context, viewerID uuid.UUID) (bool, error) {
+	if viewerID == uuid.Nil {
+		return false, nil
+	}
+	user, err := c.dbQueries.GetUserByID(ctx, viewerID)
+	if err != nil {
+		return false, err
+	}
+	return user.SensitiveContent == "hide", nil
+}
+
 func validateLength(w http.ResponseWriter, chirp chirpParams) bool {
 	chirp.Body = strings.TrimSpace(chirp.Body)
 	if len(chirp.Body) == 0 {
@@ -37,123 +74,133 @@ func (c *apiConfig) CreateChirp(w http.ResponseWriter, r *http.Request) {
 	if !validateLength(w, chirpParams) {
 		return
 	}
-	badWordsSlice := []string{"kerfuffle", "sharbert", "fornax"}
-	chirpParams.Body = cleanProfanity(chirpParams.Body, badWordsSlice)
+	chirpParams.Body = cleanProfanity(chirpParams.Body, profaneWords)
+	cw, ok := contentWarning(w, chirpParams.ContentWarning)
+	if !ok {
+		return
+	}
 
 	userID, err := c.getLoggedInUser(r)
 	if err != nil {
 		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-
-	createParams := database.CreateChirpParams{
-		Body:   chirpParams.Body,
-		UserID: userID,
-	}
-
-	chirp, err := c.dbQueries.CreateChirp(r.Context(), createParams)
+	verified, err := c.emailVerified(r.Context(), userID)
 	if err != nil {
 		respondWithError(w, "Failed to create chirp", http.StatusInternalServerError)
-		log.Printf("Error creating chirp: %v", err)
+		log.Printf("Error checking email verification: %v", err)
 		return
 	}
-	JSONChirp, err := createResponseStruct(chirp)
-	if err != nil {
-		respondWithError(w, "Failed to create chirp response", http.StatusInternalServerError)
-		log.Printf("Error creating chirp response: %v", err)
+	if !verified {
+		respondWithError(w, "Verify your email address before chirping", http.StatusForbidden)
 		return
 	}
 
-	respondWithJSON(w, JSONChirp, http.StatusCreated)
-}
-
-func (c *apiConfig) getChirpsByUser(w http.ResponseWriter, r *http.Request, id string) {
-	authorID, err := uuid.Parse(id)
-	if err != nil {
-		respondWithError(w, "Invalid author ID", http.StatusBadRequest)
-		log.Printf("Error parsing author ID: %v", err)
-		return
+	createParams := database.CreateChirpParams{
+		Body:           chirpParams.Body,
+		UserID:         userID,
+		ContentWarning: cw,
+		Sensitive:      chirpParams.Sensitive,
 	}
-	sort := r.URL.Query().Get("sort")
-	user, err := c.dbQueries.GetUserByID(r.Context(), authorID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			respondWithError(w, "User not found", http.StatusNotFound)
+	if chirpParams.ExpiresIn != nil {
+		if *chirpParams.ExpiresIn < minChirpLifetime || *chirpParams.ExpiresIn > maxChirpLifetime {
+			respondWithError(w, "expires_in must be between 60 seconds and 30 days", http.StatusBadRequest)
 			return
 		}
-		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
-		log.Printf("Error retrieving user: %v", err)
-		return
+		createParams.ExpiresIn = sql.NullInt32{Int32: *chirpParams.ExpiresIn, Valid: true}
 	}
-	var chirps []database.Chirp
-	switch sort {
-	case "":
-		fallthrough
-	case "asc":
-		chirps, err = c.dbQueries.GetChirpsByUser(r.Context(), authorID)
+
+	var parent database.Chirp
+	if chirpParams.ReplyToID != nil {
+		parent, err = c.dbQueries.GetChirpByID(r.Context(), *chirpParams.ReplyToID)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				respondWithError(w, fmt.Sprintf("No chirps found for user %s", user.Email), http.StatusNotFound)
+				respondWithError(w, "Chirp being replied to not found", http.StatusNotFound)
 				return
 			}
-			respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
-			log.Printf("Error retrieving chirps: %v", err)
+			respondWithError(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+			log.Printf("Error retrieving chirp: %v", err)
 			return
 		}
-	case "desc":
-		chirps, err = c.dbQueries.GetChirpsByUserDesc(r.Context(), authorID)
+		blocked, err := c.isBlockedEitherWay(r.Context(), userID, parent.UserID)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				respondWithError(w, fmt.Sprintf("No chirps found for user %s", user.Email), http.StatusNotFound)
-				return
-			}
-			respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
-			log.Printf("Error retrieving chirps: %v", err)
+			respondWithError(w, "Failed to create chirp", http.StatusInternalServerError)
+			log.Printf("Error checking blocks: %v", err)
 			return
 		}
-	default:
-		respondWithError(w, "Invalid sort query", http.StatusBadRequest)
+		if blocked {
+			respondWithError(w, "You cannot reply to this user", http.StatusForbidden)
+			return
+		}
+		createParams.ReplyToID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+
+	chirp, err := c.dbQueries.CreateChirp(r.Context(), createParams)
+	if err != nil {
+		respondWithError(w, "Failed to create chirp", http.StatusInternalServerError)
+		log.Printf("Error creating chirp: %v", err)
 		return
 	}
-	JSONChirps, err := createResponseStruct(chirps)
+	c.publishChirpCreated(chirp)
+	if chirp.ReplyToID.Valid {
+		c.publishNotification(parent.UserID, notification{Type: "reply", FromUserID: userID, ChirpID: chirp.ID})
+	}
+	c.federateChirp(r.Context(), chirp, "Create")
+
+	JSONChirp, err := createResponseStruct(chirp)
 	if err != nil {
 		respondWithError(w, "Failed to create chirp response", http.StatusInternalServerError)
 		log.Printf("Error creating chirp response: %v", err)
 		return
 	}
-	respondWithJSON(w, JSONChirps, http.StatusOK)
+
+	respondWithJSON(w, JSONChirp, http.StatusCreated)
 }
 
 func (c *apiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("author_id")
-	if id != "" {
-		c.getChirpsByUser(w, r, id)
+	filter, fieldErrors := parseChirpFilter(r.URL.Query())
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, "Invalid query parameters", fieldErrors)
+		return
+	}
+	fieldErrors, err := c.checkCursorChirps(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		log.Printf("Error retrieving cursor chirps: %v", err)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, "Invalid query parameters", fieldErrors)
 		return
 	}
 
-	var chirps []database.Chirp
-	var err error
-	switch r.URL.Query().Get("sort") {
-	case "":
-		fallthrough
-	case "asc":
-
-		chirps, err = c.dbQueries.GetChirps(r.Context())
-		if err != nil {
-			respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
-			log.Printf("Error retrieving chirps: %v", err)
-			return
-		}
-	case "desc":
-		chirps, err = c.dbQueries.GetChirpsDesc(r.Context())
-		if err != nil {
-			respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
-			log.Printf("Error retrieving chirps: %v", err)
-			return
-		}
+	viewerID, err := c.getViewer(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	hidden, err := c.hiddenAuthors(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		log.Printf("Error retrieving hidden authors: %v", err)
+		return
+	}
+	for id := range hidden {
+		filter.ExcludeAuthorIDs = append(filter.ExcludeAuthorIDs, id)
+	}
+	filter.HideSensitive, err = c.hidesSensitive(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		log.Printf("Error retrieving viewer preferences: %v", err)
+		return
+	}
+	filter.ViewerID = viewerID
 
-	default:
-		respondWithError(w, "Invalid sort query", http.StatusBadRequest)
+	chirps, err := c.dbQueries.ListChirps(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		log.Printf("Error retrieving chirps: %v", err)
+		return
 	}
 
 	chirpList, err := createResponseStruct(chirps)
@@ -162,8 +209,21 @@ func (c *apiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Error creating chirp response: %v", err)
 		return
 	}
+	if wantsAuthor(r) {
+		if _, err := c.attachAuthors(r.Context(), chirpList.([]Chirp)); err != nil {
+			respondWithError(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+			log.Printf("Error retrieving authors: %v", err)
+			return
+		}
+	}
+	c.recordViews(viewerID, chirps...)
+	if len(chirps) == int(filter.Limit) {
+		w.Header().Set("Link", "</api/chirps?"+nextChirpsPage(r.URL.Query(), filter.Rank, chirps[len(chirps)-1]).Encode()+`>; rel="next"`)
+	}
 
-	respondWithJSON(w, chirpList, http.StatusOK)
+	// Lists only carry an ETag: a deleted chirp changes the list without
+	// moving any updated_at, so Last-Modified would be wrong.
+	respondWithConditionalJSON(w, r, chirpList, time.Time{})
 }
 
 func (c *apiConfig) GetChirpByID(w http.ResponseWriter, r *http.Request) {
@@ -173,6 +233,11 @@ func (c *apiConfig) GetChirpByID(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, "Invalid chirp ID", http.StatusBadRequest)
 		return
 	}
+	viewerID, err := c.getViewer(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
 	chirp, err := c.dbQueries.GetChirpByID(r.Context(), id)
 	if err == sql.ErrNoRows {
@@ -184,13 +249,38 @@ func (c *apiConfig) GetChirpByID(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Error retrieving chirp: %v", err)
 		return
 	}
+	hidden, err := c.hiddenAuthors(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+		log.Printf("Error retrieving hidden authors: %v", err)
+		return
+	}
+	if _, ok := hidden[chirp.UserID]; ok {
+		respondWithError(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
 	JSONChirp, err := createResponseStruct(chirp)
 	if err != nil {
 		respondWithError(w, "Failed to create chirp response", http.StatusInternalServerError)
 		log.Printf("Error creating chirp response: %v", err)
 		return
 	}
-	respondWithJSON(w, JSONChirp, http.StatusOK)
+	lastModified := chirp.UpdatedAt
+	if wantsAuthor(r) {
+		single := []Chirp{JSONChirp.(Chirp)}
+		authorModified, err := c.attachAuthors(r.Context(), single)
+		if err != nil {
+			respondWithError(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+			log.Printf("Error retrieving author: %v", err)
+			return
+		}
+		JSONChirp = single[0]
+		if authorModified.After(lastModified) {
+			lastModified = authorModified
+		}
+	}
+	c.recordViews(viewerID, chirp)
+	respondWithConditionalJSON(w, r, JSONChirp, lastModified)
 }
 
 func (c *apiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
@@ -205,7 +295,19 @@ func (c *apiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid chirp ID", http.StatusBadRequest)
 		return
 	}
-	ChirpData, err := c.dbQueries.GetChirpByID(r.Context(), chirpID)
+
+	// The row is locked while If-Match is checked so a concurrent change
+	// can't slip in between the check and the delete.
+	tx, err := c.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Failed to delete chirp", http.StatusInternalServerError)
+		log.Printf("Error starting transaction: %v", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
+
+	ChirpData, err := qtx.GetChirpByIDForUpdate(r.Context(), chirpID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Chirp not found", http.StatusNotFound)
@@ -221,12 +323,36 @@ func (c *apiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = c.dbQueries.DeleteChirp(r.Context(), chirpID)
+	JSONChirp, err := createResponseStruct(ChirpData)
+	if err != nil {
+		http.Error(w, "Failed to delete chirp", http.StatusInternalServerError)
+		log.Printf("Error creating chirp response: %v", err)
+		return
+	}
+	etag, err := representationETag(JSONChirp)
+	if err != nil {
+		http.Error(w, "Failed to delete chirp", http.StatusInternalServerError)
+		log.Printf("Error computing ETag: %v", err)
+		return
+	}
+	if conditional.PreconditionFailed(r, etag) {
+		http.Error(w, "Chirp has been modified", http.StatusPreconditionFailed)
+		return
+	}
+
+	err = qtx.DeleteChirp(r.Context(), chirpID)
 	if err != nil {
 		http.Error(w, "Failed to delete chirp", http.StatusInternalServerError)
 		log.Printf("Error deleting chirp: %v", err)
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to delete chirp", http.StatusInternalServerError)
+		log.Printf("Error committing delete: %v", err)
+		return
+	}
+	c.publishChirpDeleted(ChirpData)
+	c.federateChirp(r.Context(), ChirpData, "Delete")
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -1,19 +1,49 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"sync/atomic"
+	"time"
 
+	"github.com/tbirddv/chirpy/internal/activitypub"
+	"github.com/tbirddv/chirpy/internal/analytics"
+	"github.com/tbirddv/chirpy/internal/auth"
 	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/mail"
+	"github.com/tbirddv/chirpy/internal/media"
+	"github.com/tbirddv/chirpy/internal/password"
+	"github.com/tbirddv/chirpy/internal/stream"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	db             *sql.DB
 	dbQueries      *database.Queries
 	platform       string
 	tokenSecret    string
 	polkaKey       string
+	baseURL        string
+	httpClient     *http.Client
+	federation     *activitypub.Queue
+	events         *stream.Broker
+	hiddenVersions hiddenVersions
+	wsTickets      wsTickets
+	views          *analytics.ViewCounter
+	media          media.Storage
+	mailer         mail.Mailer
+	// requireVerifiedEmail stops users who haven't verified their email
+	// address from chirping.
+	requireVerifiedEmail bool
+	// deletionGrace is how long a deleted account can still be restored by
+	// logging in.
+	deletionGrace  time.Duration
+	passwordPolicy *password.Policy
+	exportDir      string
+	// stopping is closed when the server starts shutting down, so that
+	// streams end instead of holding the shutdown up.
+	stopping <-chan struct{}
 }
 
 func (c *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -23,6 +53,29 @@ func (c *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
+// requirePermission guards admin and moderation endpoints. The role in the
+// access token is checked against the database as well, so a demotion takes
+// effect immediately rather than when the token expires.
+func (c *apiConfig) requirePermission(perm auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		var claims auth.Claims
+		var user database.User
+		if err == nil {
+			claims, user, err = c.authenticate(r.Context(), token)
+		}
+		if err != nil {
+			respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !claims.Role.Can(perm) || !auth.Role(user.Role).Can(perm) {
+			respondWithError(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
 func (c *apiConfig) writeMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Write([]byte(fmt.Sprintf("<html>\n<body>\n<h1>Welcome, Chirpy Admin</h1>\n<p>Chirpy has been visited %d times!</p>\n</body>\n</html>", c.fileserverHits.Load())))
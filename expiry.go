@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	expirySweepInterval = time.Minute
+	expirySweepBatch    = 500
+)
+
+// sweepExpiredChirps deletes expired chirps until ctx is cancelled. Reads
+// already hide expired chirps, so the sweeper only reclaims space and tells
+// stream subscribers and remote followers that the chirps are gone.
+func (c *apiConfig) sweepExpiredChirps(ctx context.Context) {
+	ticker := time.NewTicker(expirySweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				chirps, err := c.dbQueries.DeleteExpiredChirps(ctx, expirySweepBatch)
+				if err != nil {
+					log.Printf("Error deleting expired chirps: %v", err)
+					break
+				}
+				for _, chirp := range chirps {
+					c.publishChirpDeleted(chirp)
+					c.federateChirp(ctx, chirp, "Delete")
+				}
+				if len(chirps) < expirySweepBatch {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+const (
+	downloadExportAction  = "download-export"
+	exportTTL             = 7 * 24 * time.Hour
+	exportLinkTTL         = 15 * time.Minute
+	exportBuildTimeout    = 5 * time.Minute
+	exportCleanupInterval = time.Hour
+)
+
+func (c *apiConfig) exportPath(id uuid.UUID) string {
+	return filepath.Join(c.exportDir, id.String()+".zip")
+}
+
+// exportResponse describes an export. Ready exports get a download link
+// signed for exportLinkTTL; fetching the export again gives a fresh one.
+func (c *apiConfig) exportResponse(exp database.DataExport) dataExport {
+	resp := dataExport{
+		ID:        exp.ID,
+		Status:    exp.Status,
+		CreatedAt: exp.CreatedAt,
+		ExpiresAt: exp.ExpiresAt,
+	}
+	if exp.CompletedAt.Valid {
+		resp.CompletedAt = &exp.CompletedAt.Time
+	}
+	if exp.Status == "ready" {
+		token, err := auth.MakeActionToken(exp.UserID, downloadExportAction, exp.ID.String(), c.tokenSecret, exportLinkTTL)
+		if err != nil {
+			log.Printf("Error signing export link: %v", err)
+		} else {
+			resp.DownloadURL = c.baseURL + "/api/exports/" + exp.ID.String() + "/download?token=" + url.QueryEscape(token)
+		}
+	}
+	return resp
+}
+
+// RequestExport starts building an archive of everything stored about the
+// logged-in user. While one is still being built, it is returned instead of
+// starting another.
+func (c *apiConfig) RequestExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	exp, err := c.dbQueries.GetPendingDataExport(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		exp, err = c.dbQueries.CreateDataExport(r.Context(), database.CreateDataExportParams{
+			UserID:    userID,
+			ExpiresAt: time.Now().Add(exportTTL),
+		})
+		if err == nil {
+			go c.buildExport(exp)
+		}
+	}
+	if err != nil {
+		respondWithError(w, "Failed to start export", http.StatusInternalServerError)
+		log.Printf("Error starting export: %v", err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/users/me/exports/"+exp.ID.String())
+	respondWithJSON(w, c.exportResponse(exp), http.StatusAccepted)
+}
+
+func (c *apiConfig) GetExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid export ID", http.StatusBadRequest)
+		return
+	}
+
+	exp, err := c.dbQueries.GetDataExport(r.Context(), database.GetDataExportParams{ID: id, UserID: userID})
+	if err == sql.ErrNoRows {
+		respondWithError(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondWithError(w, "Failed to retrieve export", http.StatusInternalServerError)
+		log.Printf("Error retrieving export: %v", err)
+		return
+	}
+	respondWithJSON(w, c.exportResponse(exp), http.StatusOK)
+}
+
+// DownloadExport serves an archive to whoever holds a signed link for it,
+// so it works from a plain browser download without an Authorization
+// header.
+func (c *apiConfig) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid export ID", http.StatusBadRequest)
+		return
+	}
+	userID, exportID, err := auth.ValidateActionToken(r.URL.Query().Get("token"), downloadExportAction, c.tokenSecret)
+	if err != nil || exportID != id.String() {
+		respondWithError(w, "Invalid or expired download link", http.StatusForbidden)
+		return
+	}
+
+	exp, err := c.dbQueries.GetDataExport(r.Context(), database.GetDataExportParams{ID: id, UserID: userID})
+	if err == sql.ErrNoRows || (err == nil && (exp.Status != "ready" || exp.ExpiresAt.Before(time.Now()))) {
+		respondWithError(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondWithError(w, "Failed to retrieve export", http.StatusInternalServerError)
+		log.Printf("Error retrieving export: %v", err)
+		return
+	}
+
+	f, err := os.Open(c.exportPath(id))
+	if err != nil {
+		respondWithError(w, "Failed to read export", http.StatusInternalServerError)
+		log.Printf("Error opening export: %v", err)
+		return
+	}
+	defer f.Close()
+	name := "chirpy-export-" + exp.CreatedAt.Format("2006-01-02") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, name, exp.CompletedAt.Time, f)
+}
+
+// failInterruptedExports marks exports that were being built when the
+// server last stopped as failed, so their owners can request new ones
+// instead of polling forever.
+func (c *apiConfig) failInterruptedExports(ctx context.Context) {
+	n, err := c.dbQueries.FailPendingDataExports(ctx)
+	if err != nil {
+		log.Printf("Error failing interrupted exports: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Marked %d interrupted exports as failed", n)
+	}
+}
+
+func (c *apiConfig) buildExport(exp database.DataExport) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportBuildTimeout)
+	defer cancel()
+	status := "ready"
+	if err := c.writeExport(ctx, exp.ID, exp.UserID); err != nil {
+		log.Printf("Error building export %s: %v", exp.ID, err)
+		status = "failed"
+	}
+	// Not ctx, which has run out if the build timed out.
+	err := c.dbQueries.FinishDataExport(context.Background(), database.FinishDataExportParams{ID: exp.ID, Status: status})
+	if err != nil {
+		log.Printf("Error finishing export %s: %v", exp.ID, err)
+	}
+}
+
+type exportFile struct {
+	name string
+	data any
+}
+
+// writeExport writes the archive to a temporary file and renames it into
+// place so a download never sees a partial archive.
+func (c *apiConfig) writeExport(ctx context.Context, id, userID uuid.UUID) error {
+	files, err := c.collectExport(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.exportDir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.exportDir, ".export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := zip.NewWriter(tmp)
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.exportPath(id))
+}
+
+// collectExport gathers the user's data, one JSON file per kind. Session
+// entries leave out the refresh tokens themselves, and audit entries who
+// made each change.
+func (c *apiConfig) collectExport(ctx context.Context, userID uuid.UUID) ([]exportFile, error) {
+	user, err := c.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := c.userResponse(user)
+	if err != nil {
+		return nil, err
+	}
+
+	dbChirps, err := c.dbQueries.GetUserChirps(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chirps := []Chirp{}
+	for _, chirp := range dbChirps {
+		JSONChirp, err := createResponseStruct(chirp)
+		if err != nil {
+			return nil, err
+		}
+		chirps = append(chirps, JSONChirp.(Chirp))
+	}
+
+	dbLikes, err := c.dbQueries.GetUserLikes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	likes := []chirpActivity{}
+	for _, like := range dbLikes {
+		likes = append(likes, chirpActivity{ChirpID: like.ChirpID, CreatedAt: like.CreatedAt})
+	}
+
+	dbRechirps, err := c.dbQueries.GetUserRechirps(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	rechirps := []chirpActivity{}
+	for _, rechirp := range dbRechirps {
+		rechirps = append(rechirps, chirpActivity{ChirpID: rechirp.ChirpID, CreatedAt: rechirp.CreatedAt})
+	}
+
+	dbFollows, err := c.dbQueries.GetUserFollows(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	follows := exportFollows{Following: []relationshipEntry{}, Followers: []relationshipEntry{}}
+	for _, follow := range dbFollows {
+		if follow.FollowerID == userID {
+			follows.Following = append(follows.Following, relationshipEntry{UserID: follow.FolloweeID, CreatedAt: follow.CreatedAt})
+		} else {
+			follows.Followers = append(follows.Followers, relationshipEntry{UserID: follow.FollowerID, CreatedAt: follow.CreatedAt})
+		}
+	}
+
+	dbBlocks, err := c.dbQueries.GetBlocks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	blocks := []relationshipEntry{}
+	for _, block := range dbBlocks {
+		blocks = append(blocks, relationshipEntry{UserID: block.BlockedID, CreatedAt: block.CreatedAt})
+	}
+
+	dbMutes, err := c.dbQueries.GetMutes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	mutes := []relationshipEntry{}
+	for _, mute := range dbMutes {
+		mutes = append(mutes, relationshipEntry{UserID: mute.MutedID, CreatedAt: mute.CreatedAt})
+	}
+
+	dbTokens, err := c.dbQueries.GetUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []exportSession{}
+	for _, token := range dbTokens {
+		session := exportSession{
+			CreatedAt:  token.CreatedAt,
+			ExpiresAt:  token.ExpiresAt,
+			UserAgent:  token.UserAgent,
+			IPAddress:  token.IpAddress,
+			LastUsedAt: token.LastUsedAt,
+		}
+		if token.RevokedAt.Valid {
+			session.RevokedAt = &token.RevokedAt.Time
+		}
+		sessions = append(sessions, session)
+	}
+
+	dbAudit, err := c.dbQueries.GetUserAuditEntries(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	audit := []exportAuditEntry{}
+	for _, entry := range dbAudit {
+		e := exportAuditEntry{
+			Action:    entry.Action,
+			CreatedAt: entry.CreatedAt,
+			UserID:    entry.SubjectID,
+			Details:   entry.Details,
+		}
+		if entry.ChirpID.Valid {
+			e.ChirpID = &entry.ChirpID.UUID
+		}
+		audit = append(audit, e)
+	}
+
+	return []exportFile{
+		{"profile.json", profile},
+		{"chirps.json", chirps},
+		{"likes.json", likes},
+		{"rechirps.json", rechirps},
+		{"follows.json", follows},
+		{"blocks.json", blocks},
+		{"mutes.json", mutes},
+		{"sessions.json", sessions},
+		{"audit.json", audit},
+	}, nil
+}
+
+func (c *apiConfig) removeExportFiles(ids []uuid.UUID) {
+	for _, id := range ids {
+		if err := os.Remove(c.exportPath(id)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error deleting export %s: %v", id, err)
+		}
+	}
+}
+
+// cleanupExports deletes expired exports and their archives until ctx is
+// cancelled.
+func (c *apiConfig) cleanupExports(ctx context.Context) {
+	ticker := time.NewTicker(exportCleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ids, err := c.dbQueries.DeleteExpiredDataExports(ctx)
+			if err != nil {
+				log.Printf("Error deleting expired exports: %v", err)
+				continue
+			}
+			c.removeExportFiles(ids)
+		}
+	}
+}
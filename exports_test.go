@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tbirddv/chirpy/internal/database/dbtest"
+)
+
+func TestExportIncludesAuditEntries(t *testing.T) {
+	c := testConfig(t)
+	user := dbtest.CreateUser(t, c.dbQueries)
+	if err := grantAdmin(context.Background(), c.dbQueries, "@"+user.Handle); err != nil {
+		t.Fatalf("Failed to grant admin: %v", err)
+	}
+
+	files, err := c.collectExport(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to collect export: %v", err)
+	}
+	for _, file := range files {
+		if file.name != "audit.json" {
+			continue
+		}
+		entries := file.data.([]exportAuditEntry)
+		if len(entries) != 1 || entries[0].Action != auditSetRole || entries[0].UserID != user.ID {
+			t.Errorf("Expected one role change for the user, got %+v", entries)
+		}
+		return
+	}
+	t.Errorf("Expected audit.json in the export")
+}
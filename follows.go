@@ -0,0 +1,238 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/pagination"
+)
+
+// parsePage reads the cursor and limit query parameters shared by every
+// keyset-paginated listing.
+func parsePage(r *http.Request) (*pagination.Cursor, int32, error) {
+	cursor, err := pagination.Decode(r.URL.Query().Get("cursor"))
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, err := pagination.ParseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		return nil, 0, err
+	}
+	return cursor, limit, nil
+}
+
+func cursorParams(cursor *pagination.Cursor) (sql.NullTime, uuid.NullUUID) {
+	if cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: cursor.Time, Valid: true}, uuid.NullUUID{UUID: cursor.ID, Valid: true}
+}
+
+func (c *apiConfig) FollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if targetID == userID {
+		respondWithError(w, "You cannot follow yourself", http.StatusBadRequest)
+		return
+	}
+	if _, err := c.dbQueries.GetUserByID(r.Context(), targetID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+
+	blocked, err := c.isBlockedEitherWay(r.Context(), userID, targetID)
+	if err != nil {
+		respondWithError(w, "Failed to follow user", http.StatusInternalServerError)
+		log.Printf("Error checking blocks: %v", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, "You cannot follow this user", http.StatusForbidden)
+		return
+	}
+
+	err = c.dbQueries.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to follow user", http.StatusInternalServerError)
+		log.Printf("Error following user: %v", err)
+		return
+	}
+	c.publishNotification(targetID, notification{Type: "follow", FromUserID: userID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *apiConfig) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	err = c.dbQueries.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to unfollow user", http.StatusInternalServerError)
+		log.Printf("Error unfollowing user: %v", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *apiConfig) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	c.listFollows(w, r, true)
+}
+
+func (c *apiConfig) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	c.listFollows(w, r, false)
+}
+
+func (c *apiConfig) listFollows(w http.ResponseWriter, r *http.Request, followers bool) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := c.dbQueries.GetUserByID(r.Context(), userID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+
+	beforeTime, beforeID := cursorParams(cursor)
+	response := followListResponse{Users: []followEntry{}}
+	if followers {
+		response.Count, err = c.dbQueries.CountFollowers(r.Context(), userID)
+		if err == nil {
+			var rows []database.GetFollowersRow
+			rows, err = c.dbQueries.GetFollowers(r.Context(), database.GetFollowersParams{
+				UserID:     userID,
+				BeforeTime: beforeTime,
+				BeforeID:   beforeID,
+				Limit:      limit,
+			})
+			for _, row := range rows {
+				response.Users = append(response.Users, followEntry{UserID: row.UserID, FollowedAt: row.CreatedAt})
+			}
+		}
+	} else {
+		response.Count, err = c.dbQueries.CountFollowing(r.Context(), userID)
+		if err == nil {
+			var rows []database.GetFollowingRow
+			rows, err = c.dbQueries.GetFollowing(r.Context(), database.GetFollowingParams{
+				UserID:     userID,
+				BeforeTime: beforeTime,
+				BeforeID:   beforeID,
+				Limit:      limit,
+			})
+			for _, row := range rows {
+				response.Users = append(response.Users, followEntry{UserID: row.UserID, FollowedAt: row.CreatedAt})
+			}
+		}
+	}
+	if err != nil {
+		respondWithError(w, "Failed to retrieve follows", http.StatusInternalServerError)
+		log.Printf("Error retrieving follows: %v", err)
+		return
+	}
+
+	if len(response.Users) == int(limit) {
+		last := response.Users[len(response.Users)-1]
+		response.NextCursor = pagination.Cursor{Time: last.FollowedAt, ID: last.UserID}.Encode()
+	}
+	respondWithJSON(w, response, http.StatusOK)
+}
+
+func (c *apiConfig) GetHomeTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hideSensitive, err := c.hidesSensitive(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve timeline", http.StatusInternalServerError)
+		log.Printf("Error retrieving viewer preferences: %v", err)
+		return
+	}
+
+	beforeTime, beforeID := cursorParams(cursor)
+	chirps, err := c.dbQueries.GetHomeTimeline(r.Context(), database.GetHomeTimelineParams{
+		UserID:        userID,
+		BeforeTime:    beforeTime,
+		BeforeID:      beforeID,
+		HideSensitive: hideSensitive,
+		Limit:         limit,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to retrieve timeline", http.StatusInternalServerError)
+		log.Printf("Error retrieving home timeline: %v", err)
+		return
+	}
+
+	response := timelineResponse{Chirps: []Chirp{}}
+	for _, chirp := range chirps {
+		JSONChirp, err := createResponseStruct(chirp)
+		if err != nil {
+			respondWithError(w, "Failed to create chirp response", http.StatusInternalServerError)
+			log.Printf("Error creating chirp response: %v", err)
+			return
+		}
+		response.Chirps = append(response.Chirps, JSONChirp.(Chirp))
+	}
+	if wantsAuthor(r) {
+		if _, err := c.attachAuthors(r.Context(), response.Chirps); err != nil {
+			respondWithError(w, "Failed to retrieve timeline", http.StatusInternalServerError)
+			log.Printf("Error retrieving authors: %v", err)
+			return
+		}
+	}
+	c.recordViews(userID, chirps...)
+	if len(chirps) == int(limit) {
+		last := chirps[len(chirps)-1]
+		response.NextCursor = pagination.Cursor{Time: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	respondWithJSON(w, response, http.StatusOK)
+}
@@ -4,6 +4,7 @@ go 1.24.5
 
 require (
 	github.com/google/uuid v1.6.0 // direct
+	github.com/gorilla/websocket v1.5.3 // direct
 	github.com/joho/godotenv v1.5.1 // direct
 	github.com/lib/pq v1.10.9 // direct
 	golang.org/x/crypto v0.41.0 // direct
@@ -0,0 +1,256 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type stubRemote struct {
+	server     *httptest.Server
+	actorID    string
+	privateKey string
+	// claimedID, if set, is the id the actor document claims instead of
+	// its own URL.
+	claimedID string
+	mu        sync.Mutex
+	received  [][]byte
+	failFirst int32
+	calls     atomic.Int32
+}
+
+func newStubRemote(t *testing.T) *stubRemote {
+	t.Helper()
+	pub, priv, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate keys: %v", err)
+	}
+	stub := &stubRemote{privateKey: priv}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /actor", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		id := stub.actorID
+		if stub.claimedID != "" {
+			id = stub.claimedID
+		}
+		json.NewEncoder(w).Encode(Actor{
+			ID:                id,
+			Type:              "Person",
+			PreferredUsername: "remote",
+			Inbox:             stub.server.URL + "/inbox",
+			Outbox:            stub.server.URL + "/outbox",
+			PublicKey: PublicKey{
+				ID:           stub.actorID + "#main-key",
+				Owner:        id,
+				PublicKeyPem: pub,
+			},
+		})
+	})
+	mux.HandleFunc("POST /inbox", func(w http.ResponseWriter, r *http.Request) {
+		n := stub.calls.Add(1)
+		if n <= stub.failFirst {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
+		stub.mu.Lock()
+		stub.received = append(stub.received, buf.Bytes())
+		stub.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	stub.server = httptest.NewServer(mux)
+	stub.actorID = stub.server.URL + "/actor"
+	t.Cleanup(stub.server.Close)
+	return stub
+}
+
+func TestSignAndVerifyRequest(t *testing.T) {
+	remote := newStubRemote(t)
+	key, err := ParsePrivateKey(remote.privateKey)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+
+	body := []byte(`{"type":"Follow"}`)
+	req := httptest.NewRequest(http.MethodPost, "http://local.test/users/abc/inbox", bytes.NewReader(body))
+	if err := SignRequest(req, body, remote.actorID+"#main-key", key); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+
+	owner, got, err := VerifyRequest(req, ActorKeyFetcher(remote.server.Client()))
+	if err != nil {
+		t.Fatalf("Failed to verify request: %v", err)
+	}
+	if owner != remote.actorID {
+		t.Errorf("Expected owner %s, got %s", remote.actorID, owner)
+	}
+	if !bytes.Equal(got, body) {
+		t.Errorf("Expected body %s, got %s", body, got)
+	}
+}
+
+func TestVerifyRejectsTamperedBody(t *testing.T) {
+	remote := newStubRemote(t)
+	key, err := ParsePrivateKey(remote.privateKey)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+
+	body := []byte(`{"type":"Follow"}`)
+	tampered := []byte(`{"type":"Delete"}`)
+	req := httptest.NewRequest(http.MethodPost, "http://local.test/users/abc/inbox", bytes.NewReader(tampered))
+	if err := SignRequest(req, body, remote.actorID+"#main-key", key); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+
+	if _, _, err := VerifyRequest(req, ActorKeyFetcher(remote.server.Client())); err == nil {
+		t.Errorf("Expected tampered body to fail verification")
+	}
+}
+
+func TestVerifyRejectsActorClaimingAnotherID(t *testing.T) {
+	remote := newStubRemote(t)
+	remote.claimedID = "https://victim.example/users/alice"
+	key, err := ParsePrivateKey(remote.privateKey)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+
+	body := []byte(`{"type":"Delete"}`)
+	req := httptest.NewRequest(http.MethodPost, "http://local.test/users/abc/inbox", bytes.NewReader(body))
+	if err := SignRequest(req, body, remote.actorID+"#main-key", key); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+
+	if owner, _, err := VerifyRequest(req, ActorKeyFetcher(remote.server.Client())); err == nil {
+		t.Errorf("Expected actor claiming %s to be rejected, got owner %s", remote.claimedID, owner)
+	}
+}
+
+func TestVerifyRequiresRequestTargetAndHost(t *testing.T) {
+	remote := newStubRemote(t)
+	key, err := ParsePrivateKey(remote.privateKey)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+
+	body := []byte(`{"type":"Follow"}`)
+	req := httptest.NewRequest(http.MethodPost, "http://local.test/users/abc/inbox", bytes.NewReader(body))
+	if err := SignRequest(req, body, remote.actorID+"#main-key", key); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	// A signature over only date and digest could be replayed elsewhere.
+	req.Header.Set("Signature", strings.Replace(req.Header.Get("Signature"), `headers="(request-target) host date digest"`, `headers="date digest"`, 1))
+
+	_, _, err = VerifyRequest(req, func(ctx context.Context, keyID string) (*rsa.PublicKey, string, error) {
+		t.Fatalf("Key should not be fetched when required headers are unsigned")
+		return nil, "", nil
+	})
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestVerifyRejectsUnsignedRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "http://local.test/users/abc/inbox", bytes.NewReader([]byte("{}")))
+	_, _, err := VerifyRequest(req, func(ctx context.Context, keyID string) (*rsa.PublicKey, string, error) {
+		t.Fatalf("Key should not be fetched for unsigned request")
+		return nil, "", nil
+	})
+	if err != ErrMissingSignature {
+		t.Errorf("Expected ErrMissingSignature, got %v", err)
+	}
+}
+
+func TestQueueRetriesUntilDelivered(t *testing.T) {
+	remote := newStubRemote(t)
+	remote.failFirst = 2
+	key, err := ParsePrivateKey(remote.privateKey)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+
+	queue := NewQueue(remote.server.Client(), QueueOptions{Workers: 1, MaxAttempts: 5, BaseDelay: 10 * time.Millisecond})
+	defer queue.Close()
+
+	queue.Enqueue(Delivery{
+		Inbox:      remote.server.URL + "/inbox",
+		Body:       []byte(`{"type":"Create"}`),
+		KeyID:      remote.actorID + "#main-key",
+		PrivateKey: key,
+	})
+	queue.Wait()
+
+	if calls := remote.calls.Load(); calls != 3 {
+		t.Errorf("Expected 3 delivery attempts, got %d", calls)
+	}
+	remote.mu.Lock()
+	defer remote.mu.Unlock()
+	if len(remote.received) != 1 {
+		t.Fatalf("Expected 1 delivered activity, got %d", len(remote.received))
+	}
+}
+
+func TestEnqueueDropsWhenFull(t *testing.T) {
+	remote := newStubRemote(t)
+	key, err := ParsePrivateKey(remote.privateKey)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %v", err)
+	}
+	release := make(chan struct{})
+	var received atomic.Int32
+	inbox := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		received.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer inbox.Close()
+
+	queue := NewQueue(inbox.Client(), QueueOptions{Workers: 1, Size: 1})
+	defer queue.Close()
+
+	enqueued := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			queue.Enqueue(Delivery{Inbox: inbox.URL, Body: []byte(`{"type":"Create"}`), KeyID: remote.actorID + "#main-key", PrivateKey: key})
+		}
+		close(enqueued)
+	}()
+	select {
+	case <-enqueued:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Enqueue not to block on a full queue")
+	}
+	close(release)
+	queue.Wait()
+
+	if n := received.Load(); n < 1 || n > 2 {
+		t.Errorf("Expected the worker's delivery and the buffered one at most, got %d", n)
+	}
+}
+
+func TestParseAcct(t *testing.T) {
+	user, domain, err := ParseAcct("acct:alice@Example.com")
+	if err != nil {
+		t.Fatalf("Failed to parse acct: %v", err)
+	}
+	if user != "alice" || domain != "example.com" {
+		t.Errorf("Expected alice@example.com, got %s@%s", user, domain)
+	}
+
+	for _, resource := range []string{"alice@example.com", "acct:alice", "acct:@example.com"} {
+		if _, _, err := ParseAcct(resource); err == nil {
+			t.Errorf("Expected %q to be rejected", resource)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// PermanentError marks a delivery failure that retrying will not fix, such
+// as a 4xx response from the remote inbox.
+type PermanentError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *PermanentError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("remote inbox rejected delivery with status %d", e.StatusCode)
+}
+
+func FetchActor(ctx context.Context, client *http.Client, actorURL string) (Actor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, actorURL, nil)
+	if err != nil {
+		return Actor{}, err
+	}
+	req.Header.Set("Accept", ContentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return Actor{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Actor{}, fmt.Errorf("fetching actor %s: status %d", actorURL, resp.StatusCode)
+	}
+	var actor Actor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&actor); err != nil {
+		return Actor{}, err
+	}
+	if actor.ID == "" || actor.Inbox == "" {
+		return Actor{}, errors.New("actor document is missing id or inbox")
+	}
+	return actor, nil
+}
+
+// ActorKeyFetcher resolves keyIds by dereferencing the owning actor document,
+// which is where fediverse servers publish their public keys.
+func ActorKeyFetcher(client *http.Client) KeyFetcher {
+	return func(ctx context.Context, keyID string) (*rsa.PublicKey, string, error) {
+		actorURL, _, _ := strings.Cut(keyID, "#")
+		actor, err := FetchActor(ctx, client, actorURL)
+		if err != nil {
+			return nil, "", err
+		}
+		// The document must describe the actor it was fetched from and own
+		// the key, or any server could publish a key in another actor's name.
+		if actor.ID != actorURL {
+			return nil, "", fmt.Errorf("actor document at %s claims to be %s", actorURL, actor.ID)
+		}
+		if actor.PublicKey.ID != keyID || actor.PublicKey.Owner != actor.ID {
+			return nil, "", fmt.Errorf("actor %s does not publish key %s", actor.ID, keyID)
+		}
+		key, err := ParsePublicKey(actor.PublicKey.PublicKeyPem)
+		if err != nil {
+			return nil, "", err
+		}
+		return key, actor.ID, nil
+	}
+}
+
+func Deliver(ctx context.Context, client *http.Client, d Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Inbox, bytes.NewReader(d.Body))
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Accept", ContentType)
+	if err := SignRequest(req, d.Body, d.KeyID, d.PrivateKey); err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("remote inbox returned status %d", resp.StatusCode)
+	default:
+		return &PermanentError{StatusCode: resp.StatusCode}
+	}
+}
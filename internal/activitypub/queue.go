@@ -0,0 +1,141 @@
+package activitypub
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type Delivery struct {
+	Inbox      string
+	Body       []byte
+	KeyID      string
+	PrivateKey *rsa.PrivateKey
+	attempt    int
+}
+
+type QueueOptions struct {
+	Workers     int
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Size is how many deliveries can wait for a worker before new ones
+	// are dropped.
+	Size int
+}
+
+// Queue delivers activities to remote inboxes in the background, retrying
+// transient failures with exponential backoff. Deliveries, including those
+// waiting to be retried, are only held in memory: they are lost when the
+// process exits, and dropped with a log line when the queue is full so
+// that enqueueing never blocks a request.
+type Queue struct {
+	client  *http.Client
+	opts    QueueOptions
+	jobs    chan Delivery
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	pending sync.WaitGroup
+}
+
+func NewQueue(client *http.Client, opts QueueOptions) *Queue {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.Size <= 0 {
+		opts.Size = 256
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = 30 * time.Second
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 6 * time.Hour
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		client: client,
+		opts:   opts,
+		jobs:   make(chan Delivery, opts.Size),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for i := 0; i < opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+func (q *Queue) Enqueue(d Delivery) {
+	q.pending.Add(1)
+	q.push(d)
+}
+
+func (q *Queue) push(d Delivery) {
+	if q.ctx.Err() != nil {
+		q.pending.Done()
+		return
+	}
+	select {
+	case q.jobs <- d:
+	default:
+		log.Printf("Dropping delivery to %s: queue full", d.Inbox)
+		q.pending.Done()
+	}
+}
+
+// Wait blocks until every enqueued delivery has either succeeded or been
+// given up on. It is mainly useful in tests.
+func (q *Queue) Wait() {
+	q.pending.Wait()
+}
+
+func (q *Queue) Close() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case d := <-q.jobs:
+			q.attempt(d)
+		}
+	}
+}
+
+func (q *Queue) attempt(d Delivery) {
+	ctx, cancel := context.WithTimeout(q.ctx, 30*time.Second)
+	err := Deliver(ctx, q.client, d)
+	cancel()
+	if err == nil {
+		q.pending.Done()
+		return
+	}
+
+	d.attempt++
+	var permanent *PermanentError
+	if errors.As(err, &permanent) || d.attempt >= q.opts.MaxAttempts {
+		log.Printf("Giving up delivery to %s after %d attempts: %v", d.Inbox, d.attempt, err)
+		q.pending.Done()
+		return
+	}
+
+	delay := q.opts.BaseDelay << (d.attempt - 1)
+	if delay <= 0 || delay > q.opts.MaxDelay {
+		delay = q.opts.MaxDelay
+	}
+	log.Printf("Delivery to %s failed (attempt %d), retrying in %s: %v", d.Inbox, d.attempt, delay, err)
+	time.AfterFunc(delay, func() { q.push(d) })
+}
@@ -0,0 +1,138 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ContentType     = "application/activity+json"
+	PublicAddress   = "https://www.w3.org/ns/activitystreams#Public"
+	activityStreams = "https://www.w3.org/ns/activitystreams"
+	securityContext = "https://w3id.org/security/v1"
+)
+
+var Context = []string{activityStreams, securityContext}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Actor struct {
+	Context           any       `json:"@context,omitempty"`
+	ID                string    `json:"id"`
+	Type              string    `json:"type"`
+	PreferredUsername string    `json:"preferredUsername"`
+	Name              string    `json:"name,omitempty"`
+	Inbox             string    `json:"inbox"`
+	Outbox            string    `json:"outbox"`
+	Followers         string    `json:"followers,omitempty"`
+	PublicKey         PublicKey `json:"publicKey"`
+}
+
+type Note struct {
+	Context      any       `json:"@context,omitempty"`
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	AttributedTo string    `json:"attributedTo"`
+	Summary      string    `json:"summary,omitempty"`
+	Sensitive    bool      `json:"sensitive,omitempty"`
+	Content      string    `json:"content"`
+	Published    time.Time `json:"published"`
+	InReplyTo    string    `json:"inReplyTo,omitempty"`
+	URL          string    `json:"url,omitempty"`
+	To           []string  `json:"to,omitempty"`
+	Cc           []string  `json:"cc,omitempty"`
+}
+
+// Activity keeps Object raw because it may be an embedded object or a bare
+// IRI depending on the sender and the activity type.
+type Activity struct {
+	Context   any             `json:"@context,omitempty"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Object    json.RawMessage `json:"object"`
+	Published *time.Time      `json:"published,omitempty"`
+	To        []string        `json:"to,omitempty"`
+	Cc        []string        `json:"cc,omitempty"`
+}
+
+// OrderedCollection carries its items inline, or links to its first page
+// when there are too many to send at once.
+type OrderedCollection struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int    `json:"totalItems"`
+	First        string `json:"first,omitempty"`
+	OrderedItems []any  `json:"orderedItems,omitempty"`
+}
+
+type OrderedCollectionPage struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	PartOf       string `json:"partOf"`
+	Next         string `json:"next,omitempty"`
+	OrderedItems []any  `json:"orderedItems"`
+}
+
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href,omitempty"`
+}
+
+// ObjectID returns the id of an activity's object whether it was sent as an
+// IRI string or as an embedded object.
+func (a Activity) ObjectID() string {
+	var id string
+	if err := json.Unmarshal(a.Object, &id); err == nil {
+		return id
+	}
+	var obj struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(a.Object, &obj); err == nil {
+		return obj.ID
+	}
+	return ""
+}
+
+// ObjectType returns the type of an embedded object, or an empty string when
+// the object is a bare IRI.
+func (a Activity) ObjectType() string {
+	var obj struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(a.Object, &obj); err != nil {
+		return ""
+	}
+	return obj.Type
+}
+
+func NewActivity(id, activityType, actor string, object any, to []string) (Activity, error) {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return Activity{}, err
+	}
+	now := time.Now().UTC()
+	return Activity{
+		Context:   activityStreams,
+		ID:        id,
+		Type:      activityType,
+		Actor:     actor,
+		Object:    raw,
+		Published: &now,
+		To:        to,
+	}, nil
+}
@@ -0,0 +1,33 @@
+package activitypub
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidResource = errors.New("resource must be of the form acct:user@domain")
+
+// ParseAcct splits a WebFinger resource such as "acct:alice@example.com"
+// into its user and domain parts.
+func ParseAcct(resource string) (user, domain string, err error) {
+	acct, found := strings.CutPrefix(resource, "acct:")
+	if !found {
+		return "", "", ErrInvalidResource
+	}
+	acct = strings.TrimPrefix(acct, "@")
+	user, domain, found = strings.Cut(acct, "@")
+	if !found || user == "" || domain == "" || strings.Contains(domain, "@") {
+		return "", "", ErrInvalidResource
+	}
+	return user, strings.ToLower(domain), nil
+}
+
+func NewWebFinger(user, domain, actorURL string) WebFinger {
+	return WebFinger{
+		Subject: "acct:" + user + "@" + domain,
+		Aliases: []string{actorURL},
+		Links: []WebFingerLink{
+			{Rel: "self", Type: ContentType, Href: actorURL},
+		},
+	}
+}
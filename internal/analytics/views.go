@@ -0,0 +1,132 @@
+// Package analytics counts chirp impressions in memory so that serving a
+// chirp never turns into a database write. Counts are aggregated per chirp
+// and day and handed to a FlushFunc in batches.
+package analytics
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ViewKey identifies one chirp's views on one UTC day.
+type ViewKey struct {
+	ChirpID uuid.UUID
+	Day     time.Time
+}
+
+type FlushFunc func(ctx context.Context, counts map[ViewKey]int64) error
+
+type Options struct {
+	// FlushInterval is how often counts are written out.
+	FlushInterval time.Duration
+	// SampleRate records one in every SampleRate impressions, weighted by
+	// SampleRate, trading accuracy on small counts for less lock traffic.
+	SampleRate int
+	// MaxKeys bounds memory if flushes keep failing; further impressions
+	// for new keys are dropped until a flush succeeds.
+	MaxKeys int
+}
+
+type ViewCounter struct {
+	flush  FlushFunc
+	opts   Options
+	mu     sync.Mutex
+	counts map[ViewKey]int64
+	now    func() time.Time
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func NewViewCounter(flush FlushFunc, opts Options) *ViewCounter {
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 30 * time.Second
+	}
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = 1
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 100000
+	}
+	c := &ViewCounter{
+		flush:  flush,
+		opts:   opts,
+		counts: make(map[ViewKey]int64),
+		now:    time.Now,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Record counts one impression of each chirp.
+func (c *ViewCounter) Record(chirpIDs ...uuid.UUID) {
+	if len(chirpIDs) == 0 {
+		return
+	}
+	day := c.now().UTC().Truncate(24 * time.Hour)
+	weight := int64(c.opts.SampleRate)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range chirpIDs {
+		if weight > 1 && rand.IntN(int(weight)) != 0 {
+			continue
+		}
+		key := ViewKey{ChirpID: id, Day: day}
+		if _, ok := c.counts[key]; !ok && len(c.counts) >= c.opts.MaxKeys {
+			continue
+		}
+		c.counts[key] += weight
+	}
+}
+
+// Flush writes out the counts gathered so far. If the FlushFunc fails the
+// counts are kept and retried on the next flush.
+func (c *ViewCounter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	counts := c.counts
+	c.counts = make(map[ViewKey]int64)
+	c.mu.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+	if err := c.flush(ctx, counts); err != nil {
+		c.mu.Lock()
+		for key, n := range counts {
+			c.counts[key] += n
+		}
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (c *ViewCounter) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Flush(context.Background()); err != nil {
+				log.Printf("Error flushing view counts: %v", err)
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Close stops the periodic flush and writes out anything still pending.
+func (c *ViewCounter) Close() error {
+	close(c.stop)
+	<-c.done
+	return c.Flush(context.Background())
+}
@@ -0,0 +1,108 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFlushAggregatesPerChirpAndDay(t *testing.T) {
+	var flushed map[ViewKey]int64
+	counter := NewViewCounter(func(ctx context.Context, counts map[ViewKey]int64) error {
+		flushed = counts
+		return nil
+	}, Options{FlushInterval: time.Hour})
+	defer counter.Close()
+
+	day := time.Date(2025, 3, 1, 15, 0, 0, 0, time.UTC)
+	counter.now = func() time.Time { return day }
+	a, b := uuid.New(), uuid.New()
+	counter.Record(a, b)
+	counter.Record(a)
+
+	if err := counter.Flush(context.Background()); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	midnight := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	if got := flushed[ViewKey{ChirpID: a, Day: midnight}]; got != 2 {
+		t.Errorf("Expected 2 views for a, got %d", got)
+	}
+	if got := flushed[ViewKey{ChirpID: b, Day: midnight}]; got != 1 {
+		t.Errorf("Expected 1 view for b, got %d", got)
+	}
+}
+
+func TestFailedFlushKeepsCounts(t *testing.T) {
+	fail := true
+	var flushed map[ViewKey]int64
+	counter := NewViewCounter(func(ctx context.Context, counts map[ViewKey]int64) error {
+		if fail {
+			return errors.New("database unavailable")
+		}
+		flushed = counts
+		return nil
+	}, Options{FlushInterval: time.Hour})
+	defer counter.Close()
+
+	id := uuid.New()
+	counter.Record(id)
+	if err := counter.Flush(context.Background()); err == nil {
+		t.Fatal("Expected flush to fail")
+	}
+	counter.Record(id)
+	fail = false
+	if err := counter.Flush(context.Background()); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	var total int64
+	for _, n := range flushed {
+		total += n
+	}
+	if total != 2 {
+		t.Errorf("Expected 2 views after retry, got %d", total)
+	}
+}
+
+func TestSampledCountsAreWeighted(t *testing.T) {
+	var total int64
+	counter := NewViewCounter(func(ctx context.Context, counts map[ViewKey]int64) error {
+		for _, n := range counts {
+			total += n
+		}
+		return nil
+	}, Options{FlushInterval: time.Hour, SampleRate: 10})
+
+	id := uuid.New()
+	for i := 0; i < 10000; i++ {
+		counter.Record(id)
+	}
+	if err := counter.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if total%10 != 0 {
+		t.Errorf("Expected sampled total to be a multiple of the rate, got %d", total)
+	}
+	if total < 8000 || total > 12000 {
+		t.Errorf("Expected roughly 10000 sampled views, got %d", total)
+	}
+}
+
+func TestCloseFlushesPendingCounts(t *testing.T) {
+	flushes := 0
+	counter := NewViewCounter(func(ctx context.Context, counts map[ViewKey]int64) error {
+		flushes++
+		return nil
+	}, Options{FlushInterval: time.Hour})
+
+	counter.Record(uuid.New())
+	if err := counter.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if flushes != 1 {
+		t.Errorf("Expected 1 flush on close, got %d", flushes)
+	}
+}
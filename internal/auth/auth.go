@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"net/http"
@@ -24,34 +25,134 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	registeredClaim := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String(),
+type accessClaims struct {
+	Role      Role   `json:"role"`
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// MakeJWT signs an access token. sessionID identifies the login the token
+// was issued under, so ending that session can invalidate the token.
+func MakeJWT(userID uuid.UUID, role Role, sessionID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := accessClaims{
+		Role:      role,
+		SessionID: sessionID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, registeredClaim)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(tokenSecret))
 }
 
+type Claims struct {
+	UserID uuid.UUID
+	Role   Role
+	// SessionID is uuid.Nil for tokens issued before sessions were tracked.
+	SessionID uuid.UUID
+	ExpiresAt time.Time
+}
+
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return claims.UserID, nil
+}
+
+func ParseJWT(tokenString, tokenSecret string) (Claims, error) {
 	parser := jwt.NewParser()
-	token, err := parser.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+	var claims accessClaims
+	token, err := parser.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, jwt.ErrSignatureInvalid
 		}
 		return []byte(tokenSecret), nil
 	})
 	if err != nil {
-		return uuid.Nil, err
+		return Claims{}, err
 	}
 	id, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return Claims{}, err
+	}
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return Claims{}, err
 	}
-	return uuid.Parse(id)
+	expiresAt, err := token.Claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return Claims{}, errors.New("token has no expiration")
+	}
+	// Access tokens carry no audience; anything with one is an action token
+	// and must not authenticate requests.
+	if audience, err := token.Claims.GetAudience(); err != nil || len(audience) > 0 {
+		return Claims{}, errors.New("not an access token")
+	}
+	// Tokens issued before roles existed have no role claim.
+	role := claims.Role
+	if role == "" {
+		role = RoleUser
+	}
+	var sessionID uuid.UUID
+	if claims.SessionID != "" {
+		sessionID, err = uuid.Parse(claims.SessionID)
+		if err != nil {
+			return Claims{}, err
+		}
+	}
+	return Claims{UserID: userID, Role: role, SessionID: sessionID, ExpiresAt: expiresAt.Time}, nil
+}
+
+type actionClaims struct {
+	Binding string `json:"bnd"`
+	jwt.RegisteredClaims
+}
+
+// MakeActionToken signs a token that lets its holder perform a single kind
+// of action, such as verifying an email address, on behalf of a user. The
+// binding is returned on validation so callers can tie the token to state
+// that should invalidate it when it changes.
+func MakeActionToken(userID uuid.UUID, action, binding, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := actionClaims{
+		Binding: binding,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			Audience:  jwt.ClaimStrings{action},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(tokenSecret))
+}
+
+// ValidateActionToken checks a token made by MakeActionToken for the given
+// action and returns its user ID and binding.
+func ValidateActionToken(tokenString, action, tokenSecret string) (uuid.UUID, string, error) {
+	var claims actionClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(action),
+		jwt.WithIssuer("chirpy"),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return userID, claims.Binding, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -70,6 +171,13 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// HashToken returns the hex SHA-256 of a random token, so tokens can be
+// stored without being usable by anyone who reads the database.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	apikey := headers.Get("Authorization")
 	if apikey == "" {
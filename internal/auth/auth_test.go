@@ -24,7 +24,7 @@ func TestMakeJWT(t *testing.T) {
 	tokenSecret := "mysecret"
 	expiresIn := time.Hour
 
-	token, err := MakeJWT(userID, tokenSecret, expiresIn)
+	token, err := MakeJWT(userID, RoleUser, uuid.New(), tokenSecret, expiresIn)
 	if err != nil {
 		t.Fatalf("Failed to create JWT: %v", err)
 	}
@@ -44,7 +44,7 @@ func TestValidateJWT(t *testing.T) {
 	tokenSecret := "mysecret"
 	expiresIn := time.Hour
 
-	token, err := MakeJWT(userID, tokenSecret, expiresIn)
+	token, err := MakeJWT(userID, RoleUser, uuid.New(), tokenSecret, expiresIn)
 	if err != nil {
 		t.Fatalf("Failed to create JWT: %v", err)
 	}
@@ -64,7 +64,7 @@ func TestTimeOutJWT(t *testing.T) {
 	tokenSecret := "mysecret"
 	expiresIn := -time.Second * 5
 
-	token, err := MakeJWT(userID, tokenSecret, expiresIn)
+	token, err := MakeJWT(userID, RoleUser, uuid.New(), tokenSecret, expiresIn)
 	if err != nil {
 		t.Fatalf("Failed to create JWT: %v", err)
 	}
@@ -82,7 +82,7 @@ func TestWrongSecret(t *testing.T) {
 	tokenSecret := "mysecret"
 	expiresIn := time.Hour
 
-	token, err := MakeJWT(userID, tokenSecret, expiresIn)
+	token, err := MakeJWT(userID, RoleUser, uuid.New(), tokenSecret, expiresIn)
 	if err != nil {
 		t.Fatalf("Failed to create JWT: %v", err)
 	}
@@ -92,3 +92,132 @@ func TestWrongSecret(t *testing.T) {
 		t.Errorf("Expected token to be invalid")
 	}
 }
+
+func TestParseJWTExpiry(t *testing.T) {
+	userID := uuid.New()
+	tokenSecret := "mysecret"
+
+	token, err := MakeJWT(userID, RoleUser, uuid.New(), tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create JWT: %v", err)
+	}
+
+	claims, err := ParseJWT(token, tokenSecret)
+	if err != nil {
+		t.Fatalf("Failed to parse JWT: %v", err)
+	}
+	if claims.UserID != userID {
+		t.Errorf("Expected user ID %v, got %v", userID, claims.UserID)
+	}
+	if until := time.Until(claims.ExpiresAt); until <= 59*time.Minute || until > time.Hour {
+		t.Errorf("Expected expiry about an hour from now, got %v", until)
+	}
+}
+
+func TestJWTCarriesRoleAndSession(t *testing.T) {
+	sessionID := uuid.New()
+	token, err := MakeJWT(uuid.New(), RoleModerator, sessionID, "mysecret", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create JWT: %v", err)
+	}
+	claims, err := ParseJWT(token, "mysecret")
+	if err != nil {
+		t.Fatalf("Failed to parse JWT: %v", err)
+	}
+	if claims.Role != RoleModerator {
+		t.Errorf("Expected role %q, got %q", RoleModerator, claims.Role)
+	}
+	if claims.SessionID != sessionID {
+		t.Errorf("Expected session %v, got %v", sessionID, claims.SessionID)
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	tests := []struct {
+		role       Role
+		moderate   bool
+		administer bool
+	}{
+		{RoleUser, false, false},
+		{RoleModerator, true, false},
+		{RoleAdmin, true, true},
+		{Role("root"), false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Can(PermModerate); got != tt.moderate {
+			t.Errorf("%q.Can(PermModerate) = %v, want %v", tt.role, got, tt.moderate)
+		}
+		if got := tt.role.Can(PermAdminister); got != tt.administer {
+			t.Errorf("%q.Can(PermAdminister) = %v, want %v", tt.role, got, tt.administer)
+		}
+	}
+
+	if role, err := ParseRole("admin"); err != nil || role != RoleAdmin {
+		t.Errorf("ParseRole(admin) = %q, %v", role, err)
+	}
+	if _, err := ParseRole("Admin"); err != ErrUnknownRole {
+		t.Errorf("Expected ErrUnknownRole, got %v", err)
+	}
+}
+
+func TestRoleOutranks(t *testing.T) {
+	tests := []struct {
+		role, other Role
+		want        bool
+	}{
+		{RoleAdmin, RoleModerator, true},
+		{RoleModerator, RoleUser, true},
+		{RoleModerator, RoleAdmin, false},
+		{RoleModerator, RoleModerator, false},
+		{RoleAdmin, RoleAdmin, false},
+		{RoleModerator, Role("root"), true},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Outranks(tt.other); got != tt.want {
+			t.Errorf("%q.Outranks(%q) = %v, want %v", tt.role, tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestActionToken(t *testing.T) {
+	userID := uuid.New()
+	tokenSecret := "mysecret"
+
+	token, err := MakeActionToken(userID, "verify-email", "a@example.com", tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create action token: %v", err)
+	}
+
+	id, binding, err := ValidateActionToken(token, "verify-email", tokenSecret)
+	if err != nil {
+		t.Fatalf("Failed to validate action token: %v", err)
+	}
+	if id != userID || binding != "a@example.com" {
+		t.Errorf("Expected %v and a@example.com, got %v and %q", userID, id, binding)
+	}
+
+	if _, _, err := ValidateActionToken(token, "reset-password", tokenSecret); err == nil {
+		t.Errorf("Expected token for another action to be rejected")
+	}
+	if _, err := ValidateJWT(token, tokenSecret); err == nil {
+		t.Errorf("Expected action token to be rejected as an access token")
+	}
+
+	access, err := MakeJWT(userID, RoleUser, uuid.New(), tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create JWT: %v", err)
+	}
+	if _, _, err := ValidateActionToken(access, "verify-email", tokenSecret); err == nil {
+		t.Errorf("Expected access token to be rejected as an action token")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	hash := HashToken("abc")
+	if hash != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("Unexpected hash %s", hash)
+	}
+	if HashToken("abd") == hash {
+		t.Errorf("Expected different tokens to hash differently")
+	}
+}
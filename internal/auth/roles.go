@@ -0,0 +1,62 @@
+package auth
+
+import "errors"
+
+// Role is a user's standing on the instance. It is stored on the user and
+// copied into their access tokens.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var ErrUnknownRole = errors.New("role must be user, moderator or admin")
+
+// Permission names something a route needs the caller to be allowed to do.
+type Permission string
+
+const (
+	// PermModerate covers acting on other users' content and accounts.
+	PermModerate Permission = "moderate"
+	// PermAdminister covers instance-wide settings, metrics and roles.
+	PermAdminister Permission = "administer"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleModerator: {PermModerate},
+	RoleAdmin:     {PermModerate, PermAdminister},
+}
+
+// roleRanks orders roles for deciding who may act on whom. Unknown roles
+// rank with users.
+var roleRanks = map[Role]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+func ParseRole(s string) (Role, error) {
+	switch role := Role(s); role {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return role, nil
+	}
+	return "", ErrUnknownRole
+}
+
+// Can reports whether the role grants perm. Unknown roles grant nothing.
+func (r Role) Can(perm Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Outranks reports whether r is strictly above other, which is what acting
+// on another user's account or role requires.
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other]
+}
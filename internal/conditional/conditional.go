@@ -0,0 +1,69 @@
+// Package conditional implements the parts of RFC 9110 conditional requests
+// Chirpy uses: strong ETags over response bodies, If-None-Match and
+// If-Modified-Since for reads, and If-Match for writes.
+package conditional
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag returns a strong entity tag for a representation body.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+}
+
+func parseList(header string) []string {
+	var tags []string
+	for _, part := range strings.Split(header, ",") {
+		if tag := strings.TrimSpace(part); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// NotModified reports whether a GET or HEAD can be answered with 304.
+// If-None-Match takes precedence over If-Modified-Since and uses weak
+// comparison; If-Modified-Since is ignored when lastModified is zero.
+func NotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, tag := range parseList(header) {
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// PreconditionFailed reports whether an If-Match header rules out a write
+// to a resource whose current representation has the given ETag. Weak tags
+// never match, as required for If-Match.
+func PreconditionFailed(r *http.Request, currentETag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range parseList(header) {
+		if tag == "*" || (!strings.HasPrefix(tag, "W/") && tag == currentETag) {
+			return false
+		}
+	}
+	return true
+}
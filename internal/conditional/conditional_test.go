@@ -0,0 +1,75 @@
+package conditional
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETagIsStableAndContentSensitive(t *testing.T) {
+	a := ETag([]byte(`{"body":"hello"}`))
+	if a != ETag([]byte(`{"body":"hello"}`)) {
+		t.Errorf("Expected identical bodies to share an ETag")
+	}
+	if a == ETag([]byte(`{"body":"hello!"}`)) {
+		t.Errorf("Expected different bodies to have different ETags")
+	}
+	if a[0] != '"' || a[len(a)-1] != '"' {
+		t.Errorf("Expected quoted strong ETag, got %s", a)
+	}
+}
+
+func TestNotModifiedWithIfNoneMatch(t *testing.T) {
+	etag := ETag([]byte("x"))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	if !NotModified(req, etag, time.Time{}) {
+		t.Errorf("Expected weak match in If-None-Match list to be not modified")
+	}
+
+	req.Header.Set("If-None-Match", `"other"`)
+	// If-Modified-Since must be ignored when If-None-Match is present.
+	req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).Format(http.TimeFormat))
+	if NotModified(req, etag, time.Now().Add(-time.Hour)) {
+		t.Errorf("Expected non-matching If-None-Match to win over If-Modified-Since")
+	}
+}
+
+func TestNotModifiedWithIfModifiedSince(t *testing.T) {
+	lastModified := time.Date(2025, 8, 1, 12, 0, 0, 500, time.UTC)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	req.Header.Set("If-Modified-Since", lastModified.Format(http.TimeFormat))
+	if !NotModified(req, `"x"`, lastModified) {
+		t.Errorf("Expected sub-second modification time to count as not modified")
+	}
+
+	req.Header.Set("If-Modified-Since", lastModified.Add(-time.Minute).Format(http.TimeFormat))
+	if NotModified(req, `"x"`, lastModified) {
+		t.Errorf("Expected resource modified after If-Modified-Since to be sent")
+	}
+}
+
+func TestPreconditionFailed(t *testing.T) {
+	etag := ETag([]byte("x"))
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	if PreconditionFailed(req, etag) {
+		t.Errorf("Expected no If-Match to allow the write")
+	}
+
+	req.Header.Set("If-Match", etag)
+	if PreconditionFailed(req, etag) {
+		t.Errorf("Expected matching If-Match to allow the write")
+	}
+
+	req.Header.Set("If-Match", "W/"+etag)
+	if !PreconditionFailed(req, etag) {
+		t.Errorf("Expected weak tag in If-Match to fail")
+	}
+
+	req.Header.Set("If-Match", `"stale"`)
+	if !PreconditionFailed(req, etag) {
+		t.Errorf("Expected stale If-Match to fail")
+	}
+}
@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ChirpFilter describes a chirp listing. Every field is optional; the zero
+// value lists all chirps oldest first. It is turned into a single
+// parameterized query instead of one sqlc query per combination.
+// AuthorIDs and AuthorHandles together select the authors to list; handles
+// must already be lowercase. HideSensitive leaves out chirps with a content
+// warning or the sensitive flag, except those written by ViewerID. Cursor
+// continues the listing after the last chirp of a previous page.
+type ChirpFilter struct {
+	Rank             ChirpRank
+	AuthorIDs        []uuid.UUID
+	AuthorHandles    []string
+	ExcludeAuthorIDs []uuid.UUID
+	Since            *time.Time
+	Until            *time.Time
+	SinceID          *uuid.UUID
+	MaxID            *uuid.UUID
+	Contains         string
+	IsReply          *bool
+	HideSensitive    bool
+	ViewerID         uuid.UUID
+	Descending       bool
+	Cursor           *ChirpCursor
+	Limit            int32
+}
+
+// ChirpCursor is the position of a chirp in a listing: its created_at, or
+// for ranked listings its score, with its ID to break ties. Holding the
+// values rather than the chirp's ID keeps the position valid when the chirp
+// is deleted or its score changes.
+type ChirpCursor struct {
+	Time  time.Time
+	Score float64
+	ID    uuid.UUID
+}
+
+// ChirpRank orders a listing by one of the stored popularity scores
+// instead of creation time. Ranked listings are always highest score first.
+// SinceID and MaxID still compare creation times; scores change as chirps
+// are liked, so only a Cursor holding the score pages through them.
+type ChirpRank string
+
+const (
+	RankTop ChirpRank = "top_score"
+	RankHot ChirpRank = "hot_score"
+)
+
+const chirpColumns = "id, created_at, updated_at, body, user_id, reply_to_id, thread_id, content_warning, sensitive, expires_at, like_count, reply_count, rechirp_count, top_score, hot_score"
+
+const suspendedUserIDs = "SELECT id FROM users WHERE suspended_until > NOW()"
+
+func uuidStrings(ids []uuid.UUID) pq.StringArray {
+	strs := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strs
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (f ChirpFilter) query() (string, []any) {
+	// Expired chirps are hidden as soon as they expire, whether or not the
+	// sweeper has deleted them yet, and suspended users' chirps for as long
+	// as the suspension lasts.
+	where := []string{
+		"(expires_at IS NULL OR expires_at > NOW())",
+		"user_id NOT IN (" + suspendedUserIDs + ")",
+	}
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var authors []string
+	if len(f.AuthorIDs) > 0 {
+		authors = append(authors, "user_id = ANY("+arg(uuidStrings(f.AuthorIDs))+"::uuid[])")
+	}
+	if len(f.AuthorHandles) > 0 {
+		authors = append(authors, "user_id IN (SELECT id FROM users WHERE LOWER(handle) = ANY("+arg(pq.StringArray(f.AuthorHandles))+"::text[]))")
+	}
+	if len(authors) > 0 {
+		where = append(where, "("+strings.Join(authors, " OR ")+")")
+	}
+	if len(f.ExcludeAuthorIDs) > 0 {
+		where = append(where, "user_id <> ALL("+arg(uuidStrings(f.ExcludeAuthorIDs))+"::uuid[])")
+	}
+	if f.Since != nil {
+		where = append(where, "created_at >= "+arg(*f.Since))
+	}
+	if f.Until != nil {
+		where = append(where, "created_at < "+arg(*f.Until))
+	}
+	if f.SinceID != nil {
+		where = append(where, "(created_at, id) > (SELECT created_at, id FROM chirps WHERE id = "+arg(*f.SinceID)+")")
+	}
+	if f.MaxID != nil {
+		where = append(where, "(created_at, id) < (SELECT created_at, id FROM chirps WHERE id = "+arg(*f.MaxID)+")")
+	}
+	key := "created_at"
+	if f.Rank != "" {
+		key = string(f.Rank)
+	}
+	if f.Cursor != nil {
+		var position any = f.Cursor.Time
+		switch f.Rank {
+		case RankTop:
+			position = int64(f.Cursor.Score)
+		case RankHot:
+			position = f.Cursor.Score
+		}
+		op := ">"
+		if f.Rank != "" || f.Descending {
+			op = "<"
+		}
+		where = append(where, "("+key+", id) "+op+" ("+arg(position)+", "+arg(f.Cursor.ID)+")")
+	}
+	if f.Contains != "" {
+		where = append(where, `body ILIKE '%' || `+arg(escapeLike(f.Contains))+` || '%' ESCAPE '\'`)
+	}
+	if f.IsReply != nil {
+		if *f.IsReply {
+			where = append(where, "reply_to_id IS NOT NULL")
+		} else {
+			where = append(where, "reply_to_id IS NULL")
+		}
+	}
+	if f.HideSensitive {
+		where = append(where, "(user_id = "+arg(f.ViewerID)+" OR (NOT sensitive AND content_warning IS NULL))")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT " + chirpColumns + " FROM chirps")
+	sb.WriteString(" WHERE " + strings.Join(where, " AND "))
+	if f.Rank != "" {
+		sb.WriteString(" ORDER BY " + key + " DESC, id DESC")
+	} else if f.Descending {
+		sb.WriteString(" ORDER BY created_at DESC, id DESC")
+	} else {
+		sb.WriteString(" ORDER BY created_at ASC, id ASC")
+	}
+	if f.Limit > 0 {
+		sb.WriteString(" LIMIT " + arg(f.Limit))
+	}
+	return sb.String(), args
+}
+
+func (q *Queries) ListChirps(ctx context.Context, f ChirpFilter) ([]Chirp, error) {
+	query, args := f.query()
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.ThreadID,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.ExpiresAt,
+			&i.LikeCount,
+			&i.ReplyCount,
+			&i.RechirpCount,
+			&i.TopScore,
+			&i.HotScore,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
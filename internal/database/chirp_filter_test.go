@@ -0,0 +1,129 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEmptyFilterListsAllUnexpiredAscending(t *testing.T) {
+	query, args := ChirpFilter{}.query()
+	if !strings.Contains(query, " WHERE (expires_at IS NULL OR expires_at > NOW()) AND user_id NOT IN (SELECT id FROM users WHERE suspended_until > NOW()) ORDER BY") {
+		t.Errorf("Expected only the expiry and suspension conditions, got %q", query)
+	}
+	if !strings.HasSuffix(query, "ORDER BY created_at ASC, id ASC") {
+		t.Errorf("Expected ascending order, got %q", query)
+	}
+	if len(args) != 0 {
+		t.Errorf("Expected no args, got %v", args)
+	}
+}
+
+func TestFilterCombinesConditionsWithNumberedParams(t *testing.T) {
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	isReply := false
+	filter := ChirpFilter{
+		AuthorIDs:  []uuid.UUID{uuid.New(), uuid.New()},
+		Since:      &since,
+		Contains:   "50%_off",
+		IsReply:    &isReply,
+		Descending: true,
+		Limit:      10,
+	}
+
+	query, args := filter.query()
+	for _, want := range []string{
+		"user_id = ANY($1::uuid[])",
+		"created_at >= $2",
+		`body ILIKE '%' || $3 || '%'`,
+		"reply_to_id IS NULL",
+		"ORDER BY created_at DESC, id DESC",
+		"LIMIT $4",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Expected query to contain %q, got %q", want, query)
+		}
+	}
+	if len(args) != 4 {
+		t.Fatalf("Expected 4 args, got %d", len(args))
+	}
+	if args[2] != `50\%\_off` {
+		t.Errorf("Expected LIKE wildcards to be escaped, got %q", args[2])
+	}
+}
+
+func TestFilterNeverInterpolatesValues(t *testing.T) {
+	malicious := "'; DROP TABLE chirps; --"
+	query, _ := ChirpFilter{Contains: malicious}.query()
+	if strings.Contains(query, "DROP TABLE") {
+		t.Errorf("Expected value to be passed as a parameter, got %q", query)
+	}
+}
+
+func TestHideSensitiveKeepsViewersOwnChirps(t *testing.T) {
+	viewer := uuid.New()
+	query, args := ChirpFilter{HideSensitive: true, ViewerID: viewer}.query()
+	want := "(user_id = $1 OR (NOT sensitive AND content_warning IS NULL))"
+	if !strings.Contains(query, want) {
+		t.Errorf("Expected query to contain %q, got %q", want, query)
+	}
+	if len(args) != 1 || args[0] != viewer {
+		t.Errorf("Expected viewer ID as the only arg, got %v", args)
+	}
+}
+
+func TestRankedFilterBoundsIDsByTime(t *testing.T) {
+	maxID := uuid.New()
+	query, _ := ChirpFilter{
+		Rank:      RankHot,
+		AuthorIDs: []uuid.UUID{uuid.New()},
+		MaxID:     &maxID,
+	}.query()
+	for _, want := range []string{
+		"(created_at, id) < (SELECT created_at, id FROM chirps WHERE id = $2)",
+		"ORDER BY hot_score DESC, id DESC",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Expected query to contain %q, got %q", want, query)
+		}
+	}
+}
+
+func TestAuthorIDsAndHandlesAreAlternatives(t *testing.T) {
+	query, args := ChirpFilter{
+		AuthorIDs:     []uuid.UUID{uuid.New()},
+		AuthorHandles: []string{"alice"},
+	}.query()
+	want := "(user_id = ANY($1::uuid[]) OR user_id IN (SELECT id FROM users WHERE LOWER(handle) = ANY($2::text[])))"
+	if !strings.Contains(query, want) {
+		t.Errorf("Expected query to contain %q, got %q", want, query)
+	}
+	if len(args) != 2 {
+		t.Errorf("Expected 2 args, got %d", len(args))
+	}
+}
+
+func TestCursorContinuesInListingOrder(t *testing.T) {
+	cursor := &ChirpCursor{Time: time.Now(), Score: 12, ID: uuid.New()}
+	tests := []struct {
+		filter   ChirpFilter
+		want     string
+		position any
+	}{
+		{ChirpFilter{Cursor: cursor}, "(created_at, id) > ($1, $2)", cursor.Time},
+		{ChirpFilter{Cursor: cursor, Descending: true}, "(created_at, id) < ($1, $2)", cursor.Time},
+		{ChirpFilter{Cursor: cursor, Rank: RankTop}, "(top_score, id) < ($1, $2)", int64(12)},
+		{ChirpFilter{Cursor: cursor, Rank: RankHot}, "(hot_score, id) < ($1, $2)", float64(12)},
+	}
+	for _, tt := range tests {
+		query, args := tt.filter.query()
+		if !strings.Contains(query, tt.want) {
+			t.Errorf("Expected query to contain %q, got %q", tt.want, query)
+		}
+		if len(args) != 2 || args[0] != tt.position || args[1] != cursor.ID {
+			t.Errorf("Expected %v and the cursor ID as args, got %v", tt.position, args)
+		}
+	}
+}
@@ -7,23 +7,54 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const countUserChirps = `-- name: CountUserChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+    AND (expires_at IS NULL OR expires_at > NOW())
+    AND user_id NOT IN (SELECT id FROM users WHERE suspended_until > NOW())
+`
+
+// Counts the chirps ListChirps would show for the user.
+func (q *Queries) CountUserChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUserChirps, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT into chirps (id, user_id, body) 
-values (gen_random_uuid(), $1, $2)
-RETURNING id, created_at, updated_at, body, user_id
+INSERT into chirps (id, user_id, body, reply_to_id, thread_id, content_warning, sensitive, expires_at)
+values (
+    gen_random_uuid(), $1, $2, $3,
+    (SELECT COALESCE(parent.thread_id, parent.id) FROM chirps parent WHERE parent.id = $3),
+    $4, $5, NOW() + $6::integer * INTERVAL '1 second'
+)
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, thread_id, content_warning, sensitive, expires_at, like_count, reply_count, rechirp_count, top_score, hot_score
 `
 
 type CreateChirpParams struct {
-	UserID uuid.UUID
-	Body   string
+	UserID         uuid.UUID
+	Body           string
+	ReplyToID      uuid.NullUUID
+	ContentWarning sql.NullString
+	Sensitive      bool
+	ExpiresIn      sql.NullInt32
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.UserID, arg.Body)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.UserID,
+		arg.Body,
+		arg.ReplyToID,
+		arg.ContentWarning,
+		arg.Sensitive,
+		arg.ExpiresIn,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -31,6 +62,16 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.ExpiresAt,
+		&i.LikeCount,
+		&i.ReplyCount,
+		&i.RechirpCount,
+		&i.TopScore,
+		&i.HotScore,
 	)
 	return i, err
 }
@@ -44,30 +85,22 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id from chirps where id = $1
-`
-
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpByID, id)
-	var i Chirp
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Body,
-		&i.UserID,
-	)
-	return i, err
-}
-
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id from chirps
-ORDER BY created_at ASC
+const deleteExpiredChirps = `-- name: DeleteExpiredChirps :many
+DELETE FROM chirps
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE expires_at <= NOW()
+    ORDER BY expires_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, thread_id, content_warning, sensitive, expires_at, like_count, reply_count, rechirp_count, top_score, hot_score
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+// SKIP LOCKED lets several instances sweep at once without deleting the
+// same rows or waiting on each other.
+func (q *Queries) DeleteExpiredChirps(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, deleteExpiredChirps, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -81,6 +114,16 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
+			&i.ThreadID,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.ExpiresAt,
+			&i.LikeCount,
+			&i.ReplyCount,
+			&i.RechirpCount,
+			&i.TopScore,
+			&i.HotScore,
 		); err != nil {
 			return nil, err
 		}
@@ -95,14 +138,13 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getChirpsByUser = `-- name: GetChirpsByUser :many
-SELECT id, created_at, updated_at, body, user_id from chirps
-WHERE user_id = $1
-ORDER BY created_at ASC
+const deleteUserChirps = `-- name: DeleteUserChirps :many
+DELETE FROM chirps WHERE user_id = $1
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, thread_id, content_warning, sensitive, expires_at, like_count, reply_count, rechirp_count, top_score, hot_score
 `
 
-func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUser, userID)
+func (q *Queries) DeleteUserChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, deleteUserChirps, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -116,6 +158,16 @@ func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chir
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
+			&i.ThreadID,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.ExpiresAt,
+			&i.LikeCount,
+			&i.ReplyCount,
+			&i.RechirpCount,
+			&i.TopScore,
+			&i.HotScore,
 		); err != nil {
 			return nil, err
 		}
@@ -130,48 +182,66 @@ func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chir
 	return items, nil
 }
 
-const getChirpsByUserDesc = `-- name: GetChirpsByUserDesc :many
-SELECT id, created_at, updated_at, body, user_id from chirps
-WHERE user_id = $1
-ORDER BY created_at DESC
+const getChirpByID = `-- name: GetChirpByID :one
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, thread_id, content_warning, sensitive, expires_at, like_count, reply_count, rechirp_count, top_score, hot_score from chirps where id = $1 AND (expires_at IS NULL OR expires_at > NOW())
 `
 
-func (q *Queries) GetChirpsByUserDesc(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserDesc, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []Chirp
-	for rows.Next() {
-		var i Chirp
-		if err := rows.Scan(
-			&i.ID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.Body,
-			&i.UserID,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.ExpiresAt,
+		&i.LikeCount,
+		&i.ReplyCount,
+		&i.RechirpCount,
+		&i.TopScore,
+		&i.HotScore,
+	)
+	return i, err
 }
 
-const getChirpsDesc = `-- name: GetChirpsDesc :many
-SELECT id, created_at, updated_at, body, user_id from chirps
-ORDER BY created_at DESC
+const getChirpByIDForUpdate = `-- name: GetChirpByIDForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, thread_id, content_warning, sensitive, expires_at, like_count, reply_count, rechirp_count, top_score, hot_score from chirps where id = $1 AND (expires_at IS NULL OR expires_at > NOW()) FOR UPDATE
 `
 
-func (q *Queries) GetChirpsDesc(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsDesc)
+func (q *Queries) GetChirpByIDForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIDForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.ExpiresAt,
+		&i.LikeCount,
+		&i.ReplyCount,
+		&i.RechirpCount,
+		&i.TopScore,
+		&i.HotScore,
+	)
+	return i, err
+}
+
+const getUserChirps = `-- name: GetUserChirps :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, thread_id, content_warning, sensitive, expires_at, like_count, reply_count, rechirp_count, top_score, hot_score FROM chirps WHERE user_id = $1 ORDER BY created_at, id
+`
+
+func (q *Queries) GetUserChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getUserChirps, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -185,6 +255,16 @@ func (q *Queries) GetChirpsDesc(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
+			&i.ThreadID,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.ExpiresAt,
+			&i.LikeCount,
+			&i.ReplyCount,
+			&i.RechirpCount,
+			&i.TopScore,
+			&i.HotScore,
 		); err != nil {
 			return nil, err
 		}
@@ -198,3 +278,38 @@ func (q *Queries) GetChirpsDesc(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const setChirpContentWarning = `-- name: SetChirpContentWarning :one
+UPDATE chirps SET content_warning = $2, sensitive = $3, updated_at = NOW()
+WHERE id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, thread_id, content_warning, sensitive, expires_at, like_count, reply_count, rechirp_count, top_score, hot_score
+`
+
+type SetChirpContentWarningParams struct {
+	ID             uuid.UUID
+	ContentWarning sql.NullString
+	Sensitive      bool
+}
+
+func (q *Queries) SetChirpContentWarning(ctx context.Context, arg SetChirpContentWarningParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, setChirpContentWarning, arg.ID, arg.ContentWarning, arg.Sensitive)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.ExpiresAt,
+		&i.LikeCount,
+		&i.ReplyCount,
+		&i.RechirpCount,
+		&i.TopScore,
+		&i.HotScore,
+	)
+	return i, err
+}
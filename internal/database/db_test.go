@@ -0,0 +1,44 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/database/dbtest"
+)
+
+func TestDeletingThreadRootKeepsReplies(t *testing.T) {
+	q := database.New(dbtest.Open(t))
+	ctx := context.Background()
+	author := dbtest.CreateUser(t, q)
+	replier := dbtest.CreateUser(t, q)
+
+	root, err := q.CreateChirp(ctx, database.CreateChirpParams{UserID: author.ID, Body: "root"})
+	if err != nil {
+		t.Fatalf("Failed to create root: %v", err)
+	}
+	reply, err := q.CreateChirp(ctx, database.CreateChirpParams{
+		UserID:    replier.ID,
+		Body:      "reply",
+		ReplyToID: uuid.NullUUID{UUID: root.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create reply: %v", err)
+	}
+	if reply.ThreadID.UUID != root.ID {
+		t.Fatalf("Expected reply in thread %v, got %v", root.ID, reply.ThreadID)
+	}
+
+	if err := q.DeleteChirp(ctx, root.ID); err != nil {
+		t.Fatalf("Failed to delete root: %v", err)
+	}
+	got, err := q.GetChirpByID(ctx, reply.ID)
+	if err != nil {
+		t.Fatalf("Expected reply to survive deleting the root: %v", err)
+	}
+	if got.ThreadID.Valid || got.ReplyToID.Valid {
+		t.Errorf("Expected reply to be detached from the deleted root, got thread %v, parent %v", got.ThreadID, got.ReplyToID)
+	}
+}
@@ -0,0 +1,48 @@
+// Package dbtest has helpers for tests that run against the migrated
+// database in TEST_DB_URL.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+// Open connects to TEST_DB_URL, skipping the test if it isn't set. The
+// connection is closed when the test ends.
+func Open(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// CreateUser adds a user with a unique email and handle, deleted again
+// when the test ends.
+func CreateUser(t *testing.T, q *database.Queries) database.User {
+	t.Helper()
+	name := "t" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	user, err := q.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          name + "@example.com",
+		HashedPassword: "x",
+		Handle:         name,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	t.Cleanup(func() { q.DeleteUser(context.Background(), user.ID) })
+	return user
+}
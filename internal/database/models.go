@@ -6,33 +6,155 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-type Chirp struct {
+type ActorKey struct {
+	UserID        uuid.UUID
+	PublicKeyPem  string
+	PrivateKeyPem string
+	CreatedAt     time.Time
+}
+
+type AuditLog struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
+	ActorID   uuid.NullUUID
+	SubjectID uuid.UUID
+	Action    string
+	ChirpID   uuid.NullUUID
+	Details   json.RawMessage
+}
+
+type BannedEmailDomain struct {
+	Domain    string
+	Reason    string
+	CreatedAt time.Time
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type Chirp struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	ReplyToID      uuid.NullUUID
+	ThreadID       uuid.NullUUID
+	ContentWarning sql.NullString
+	Sensitive      bool
+	ExpiresAt      sql.NullTime
+	LikeCount      int32
+	ReplyCount     int32
+	RechirpCount   int32
+	TopScore       int32
+	HotScore       float64
+}
+
+type ChirpView struct {
+	ChirpID uuid.UUID
+	Day     time.Time
+	Views   int64
+}
+
+type DataExport struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Status      string
+	CreatedAt   time.Time
+	CompletedAt sql.NullTime
+	ExpiresAt   time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Like struct {
 	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
 }
 
-type RefreshToken struct {
-	Token     string
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+}
+
+type PasswordResetToken struct {
+	TokenHash string
 	UserID    uuid.UUID
+	CreatedAt time.Time
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+}
+
+type Rechirp struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type RefreshToken struct {
+	Token      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	UserAgent  string
+	IpAddress  string
+	LastUsedAt time.Time
+	ReplacedBy sql.NullString
+}
+
+type RemoteFollower struct {
+	UserID    uuid.UUID
+	ActorID   string
+	Inbox     string
+	CreatedAt time.Time
+}
+
+type RemotePost struct {
+	ApID        string
+	ActorID     string
+	Content     string
+	InReplyTo   sql.NullString
+	PublishedAt time.Time
+	CreatedAt   time.Time
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         bool
+	SensitiveContent    string
+	Handle              string
+	DisplayName         string
+	Bio                 string
+	Location            string
+	Website             string
+	AvatarKey           sql.NullString
+	BannerKey           sql.NullString
+	EmailVerifiedAt     sql.NullTime
+	PendingEmail        sql.NullString
+	DeletionScheduledAt sql.NullTime
+	FollowerCount       int32
+	SuspendedUntil      sql.NullTime
+	SuspensionReason    sql.NullString
+	Role                string
 }
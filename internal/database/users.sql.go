@@ -7,23 +7,35 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users SET deletion_scheduled_at = NULL, updated_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, email, hashed_password)
-VALUES (gen_random_uuid(), $1, $2)
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+INSERT INTO users (id, email, hashed_password, handle)
+VALUES (gen_random_uuid(), $1, $2, $3)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Handle)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -32,10 +44,34 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
 const deleteUsers = `-- name: DeleteUsers :exec
 DELETE FROM users
 `
@@ -46,11 +82,44 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role FROM users WHERE LOWER(email) = LOWER($1)
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, lower)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role FROM users WHERE LOWER(handle) = LOWER($1)
 `
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+func (q *Queries) GetUserByHandle(ctx context.Context, lower string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, lower)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -59,12 +128,27 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE id = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
@@ -77,12 +161,161 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
 	)
 	return i, err
 }
 
+const getUserByIDForUpdate = `-- name: GetUserByIDForUpdate :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role FROM users WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) GetUserByIDForUpdate(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByIDForUpdate, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
+	)
+	return i, err
+}
+
+const getUsersByIDs = `-- name: GetUsersByIDs :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role FROM users WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.SensitiveContent,
+			&i.Handle,
+			&i.DisplayName,
+			&i.Bio,
+			&i.Location,
+			&i.Website,
+			&i.AvatarKey,
+			&i.BannerKey,
+			&i.EmailVerifiedAt,
+			&i.PendingEmail,
+			&i.DeletionScheduledAt,
+			&i.FollowerCount,
+			&i.SuspendedUntil,
+			&i.SuspensionReason,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUsersDueForDeletion = `-- name: GetUsersDueForDeletion :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role FROM users
+WHERE deletion_scheduled_at <= NOW()
+ORDER BY deletion_scheduled_at
+LIMIT $1
+`
+
+func (q *Queries) GetUsersDueForDeletion(ctx context.Context, limit int32) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersDueForDeletion, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.SensitiveContent,
+			&i.Handle,
+			&i.DisplayName,
+			&i.Bio,
+			&i.Location,
+			&i.Website,
+			&i.AvatarKey,
+			&i.BannerKey,
+			&i.EmailVerifiedAt,
+			&i.PendingEmail,
+			&i.DeletionScheduledAt,
+			&i.FollowerCount,
+			&i.SuspendedUntil,
+			&i.SuspensionReason,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const giveChirpyRed = `-- name: GiveChirpyRed :one
-UPDATE users SET is_chirpy_red = true, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+UPDATE users SET is_chirpy_red = true, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
 `
 
 func (q *Queries) GiveChirpyRed(ctx context.Context, id uuid.UUID) (User, error) {
@@ -95,22 +328,499 @@ func (q *Queries) GiveChirpyRed(ctx context.Context, id uuid.UUID) (User, error)
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
 	)
 	return i, err
 }
 
-const updateUser = `-- name: UpdateUser :one
-UPDATE users SET email = $1, hashed_password = $2, updated_at = NOW() WHERE id = $3 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+const lockAdmins = `-- name: LockAdmins :many
+SELECT id FROM users WHERE role = 'admin' AND deletion_scheduled_at IS NULL FOR UPDATE
 `
 
-type UpdateUserParams struct {
-	Email          string
-	HashedPassword string
+func (q *Queries) LockAdmins(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, lockAdmins)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :one
+UPDATE users SET deletion_scheduled_at = $2, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
+`
+
+type ScheduleUserDeletionParams struct {
+	ID                  uuid.UUID
+	DeletionScheduledAt sql.NullTime
+}
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, scheduleUserDeletion, arg.ID, arg.DeletionScheduledAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
+	)
+	return i, err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role FROM users
+WHERE deletion_scheduled_at IS NULL
+  AND (suspended_until IS NULL OR suspended_until <= NOW())
+  AND ($1::text = ''
+       OR LOWER(handle) LIKE $2::text
+       OR LOWER(display_name) LIKE $2::text
+       OR LOWER(handle) % $1::text
+       OR LOWER(display_name) % $1::text)
+  AND NOT EXISTS (
+      SELECT 1 FROM blocks
+      WHERE (blocker_id = $3 AND blocked_id = users.id)
+         OR (blocker_id = users.id AND blocked_id = $3)
+  )
+  AND ($4::integer IS NULL
+       OR (follower_count, id) < ($4::integer, $5::uuid))
+ORDER BY follower_count DESC, id DESC
+LIMIT $6
+`
+
+type SearchUsersParams struct {
+	Query       string
+	Prefix      string
+	ViewerID    uuid.UUID
+	BeforeCount sql.NullInt32
+	BeforeID    uuid.NullUUID
+	Limit       int32
+}
+
+// Matches handles and display names that start with the query or are
+// trigram-similar to it; an empty query lists everyone. Users blocked
+// either way by the viewer, suspended users and accounts being deleted are
+// left out.
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers,
+		arg.Query,
+		arg.Prefix,
+		arg.ViewerID,
+		arg.BeforeCount,
+		arg.BeforeID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.SensitiveContent,
+			&i.Handle,
+			&i.DisplayName,
+			&i.Bio,
+			&i.Location,
+			&i.Website,
+			&i.AvatarKey,
+			&i.BannerKey,
+			&i.EmailVerifiedAt,
+			&i.PendingEmail,
+			&i.DeletionScheduledAt,
+			&i.FollowerCount,
+			&i.SuspendedUntil,
+			&i.SuspensionReason,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setAvatar = `-- name: SetAvatar :one
+UPDATE users SET avatar_key = $2, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
+`
+
+type SetAvatarParams struct {
+	ID        uuid.UUID
+	AvatarKey sql.NullString
+}
+
+func (q *Queries) SetAvatar(ctx context.Context, arg SetAvatarParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setAvatar, arg.ID, arg.AvatarKey)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
+	)
+	return i, err
+}
+
+const setBanner = `-- name: SetBanner :one
+UPDATE users SET banner_key = $2, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
+`
+
+type SetBannerParams struct {
+	ID        uuid.UUID
+	BannerKey sql.NullString
+}
+
+func (q *Queries) SetBanner(ctx context.Context, arg SetBannerParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setBanner, arg.ID, arg.BannerKey)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
+	)
+	return i, err
+}
+
+const setPendingEmail = `-- name: SetPendingEmail :exec
+UPDATE users SET pending_email = $2, updated_at = NOW() WHERE id = $1
+`
+
+type SetPendingEmailParams struct {
+	ID           uuid.UUID
+	PendingEmail sql.NullString
+}
+
+func (q *Queries) SetPendingEmail(ctx context.Context, arg SetPendingEmailParams) error {
+	_, err := q.db.ExecContext(ctx, setPendingEmail, arg.ID, arg.PendingEmail)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users SET role = $2, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users SET suspended_until = $2, suspension_reason = $3, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
+`
+
+type SuspendUserParams struct {
+	ID               uuid.UUID
+	SuspendedUntil   sql.NullTime
+	SuspensionReason sql.NullString
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, arg.ID, arg.SuspendedUntil, arg.SuspensionReason)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
+	)
+	return i, err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :one
+UPDATE users SET suspended_until = NULL, suspension_reason = NULL, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, unsuspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
+	)
+	return i, err
+}
+
+const updatePassword = `-- name: UpdatePassword :exec
+UPDATE users SET hashed_password = $2, updated_at = NOW() WHERE id = $1
+`
+
+type UpdatePasswordParams struct {
 	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updatePassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const updateProfile = `-- name: UpdateProfile :one
+UPDATE users SET
+    display_name = COALESCE($1, display_name),
+    bio = COALESCE($2, bio),
+    location = COALESCE($3, location),
+    website = COALESCE($4, website),
+    updated_at = NOW()
+WHERE id = $5
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
+`
+
+type UpdateProfileParams struct {
+	DisplayName sql.NullString
+	Bio         sql.NullString
+	Location    sql.NullString
+	Website     sql.NullString
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateProfile(ctx context.Context, arg UpdateProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateProfile,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+		arg.Website,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
+	)
+	return i, err
+}
+
+const updateSensitiveContent = `-- name: UpdateSensitiveContent :one
+UPDATE users SET sensitive_content = $2, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
+`
+
+type UpdateSensitiveContentParams struct {
+	ID               uuid.UUID
+	SensitiveContent string
+}
+
+func (q *Queries) UpdateSensitiveContent(ctx context.Context, arg UpdateSensitiveContentParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateSensitiveContent, arg.ID, arg.SensitiveContent)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
+	)
+	return i, err
+}
+
+const verifyEmail = `-- name: VerifyEmail :one
+UPDATE users SET
+    email = $2,
+    pending_email = CASE WHEN pending_email = $2 THEN NULL ELSE pending_email END,
+    email_verified_at = CASE WHEN email = $2 THEN COALESCE(email_verified_at, NOW()) ELSE NOW() END,
+    updated_at = NOW()
+WHERE id = $1 AND (email = $2 OR pending_email = $2)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, sensitive_content, handle, display_name, bio, location, website, avatar_key, banner_key, email_verified_at, pending_email, deletion_scheduled_at, follower_count, suspended_until, suspension_reason, role
+`
+
+type VerifyEmailParams struct {
+	ID    uuid.UUID
+	Email string
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.Email, arg.HashedPassword, arg.ID)
+// Verifies the current address, or swaps in the pending one it matches.
+func (q *Queries) VerifyEmail(ctx context.Context, arg VerifyEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyEmail, arg.ID, arg.Email)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -119,6 +829,21 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SensitiveContent,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.AvatarKey,
+		&i.BannerKey,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DeletionScheduledAt,
+		&i.FollowerCount,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.Role,
 	)
 	return i, err
 }
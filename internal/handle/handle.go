@@ -0,0 +1,75 @@
+// Package handle validates the public names users are known by. Handles
+// are unique regardless of case, so comparisons go through Normalize.
+package handle
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	MinLength = 3
+	MaxLength = 30
+)
+
+var (
+	ErrLength   = errors.New("handle must be between 3 and 30 characters")
+	ErrCharset  = errors.New("handle may only contain letters, digits and underscores, and must start with a letter")
+	ErrReserved = errors.New("handle is reserved")
+)
+
+// reserved holds names that collide with routes or could be mistaken for
+// the service itself.
+var reserved = map[string]struct{}{
+	"about":     {},
+	"admin":     {},
+	"api":       {},
+	"app":       {},
+	"chirpy":    {},
+	"help":      {},
+	"me":        {},
+	"moderator": {},
+	"root":      {},
+	"security":  {},
+	"settings":  {},
+	"staff":     {},
+	"support":   {},
+	"system":    {},
+	"users":     {},
+}
+
+func Normalize(h string) string {
+	return strings.ToLower(h)
+}
+
+// Validate reports whether h is acceptable as a new handle.
+func Validate(h string) error {
+	if len(h) < MinLength || len(h) > MaxLength {
+		return ErrLength
+	}
+	if !Valid(h) {
+		return ErrCharset
+	}
+	if _, ok := reserved[Normalize(h)]; ok {
+		return ErrReserved
+	}
+	return nil
+}
+
+// Valid reports whether h is syntactically a handle, without checking
+// length limits or reserved names. It is used to tell handles from other
+// identifiers in lookups.
+func Valid(h string) bool {
+	if h == "" {
+		return false
+	}
+	for i, r := range h {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '_'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+package handle
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		handle string
+		want   error
+	}{
+		{"alice", nil},
+		{"Bob_42", nil},
+		{"ab", ErrLength},
+		{"a234567890123456789012345678901", ErrLength},
+		{"1alice", ErrCharset},
+		{"_alice", ErrCharset},
+		{"alice-smith", ErrCharset},
+		{"ålice", ErrCharset},
+		{"Admin", ErrReserved},
+		{"me_", nil},
+	}
+	for _, tt := range tests {
+		if got := Validate(tt.handle); got != tt.want {
+			t.Errorf("Validate(%q) = %v, want %v", tt.handle, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeIsCaseInsensitive(t *testing.T) {
+	if Normalize("Alice") != Normalize("aLICE") {
+		t.Error("Expected handles differing only in case to normalize equally")
+	}
+}
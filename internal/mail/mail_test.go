@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerWritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: dir, From: "Chirpy <noreply@example.com>"}
+	err := m.Send(context.Background(), Message{
+		To:      "alice@example.com",
+		Subject: "Héllo",
+		Body:    "line one\nline two",
+	})
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one .eml file, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	msg := string(data)
+	for _, want := range []string{
+		"From: Chirpy <noreply@example.com>\r\n",
+		"To: alice@example.com\r\n",
+		"Subject: =?utf-8?q?H=C3=A9llo?=\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected message to contain %q, got:\n%s", want, msg)
+		}
+	}
+}
+
+func TestRejectsHeaderInjection(t *testing.T) {
+	m := &FileMailer{Dir: t.TempDir(), From: "noreply@example.com"}
+	err := m.Send(context.Background(), Message{
+		To:      "alice@example.com\r\nBcc: eve@example.com",
+		Subject: "Hi",
+	})
+	if err != ErrInvalidHeader {
+		t.Errorf("Expected ErrInvalidHeader, got %v", err)
+	}
+}
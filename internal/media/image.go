@@ -0,0 +1,118 @@
+// Package media stores user uploads and prepares images for them.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+// maxSourcePixels bounds the decoded size of an upload, so a small file
+// that expands to a huge bitmap is rejected before it is decoded.
+const maxSourcePixels = 25_000_000
+
+var (
+	ErrUnsupportedImage = errors.New("image must be a JPEG, PNG or GIF")
+	ErrImageTooLarge    = errors.New("image dimensions are too large")
+)
+
+// Spec is the size an uploaded image is cropped and scaled to.
+type Spec struct {
+	Width  int
+	Height int
+}
+
+var (
+	Avatar = Spec{Width: 400, Height: 400}
+	Banner = Spec{Width: 1500, Height: 500}
+)
+
+// Process decodes an uploaded image, centre-crops it to the spec's aspect
+// ratio, scales it to the spec's size and re-encodes it as JPEG. Re-encoding
+// also strips any metadata the original carried.
+func Process(r io.Reader, spec Spec) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxSourcePixels {
+		return nil, ErrImageTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	// Flatten onto white: JPEG has no alpha channel.
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Over)
+
+	out := scale(rgba.SubImage(cropRect(rgba.Bounds(), spec)).(*image.RGBA), spec)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, out, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cropRect returns the largest centred rectangle of b with the spec's
+// aspect ratio. It is never less than one pixel across, however small or
+// narrow b is, so scale always has something to sample.
+func cropRect(b image.Rectangle, spec Spec) image.Rectangle {
+	w, h := b.Dx(), b.Dy()
+	if w*spec.Height > h*spec.Width {
+		cw := max(h*spec.Width/spec.Height, 1)
+		x := b.Min.X + (w-cw)/2
+		return image.Rect(x, b.Min.Y, x+cw, b.Max.Y)
+	}
+	ch := max(w*spec.Height/spec.Width, 1)
+	y := b.Min.Y + (h-ch)/2
+	return image.Rect(b.Min.X, y, b.Max.X, y+ch)
+}
+
+// scale resizes src to the spec with a box filter: each output pixel is the
+// average of the source pixels it covers, or the nearest one when scaling
+// up.
+func scale(src *image.RGBA, spec Spec) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, spec.Width, spec.Height))
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	for dy := 0; dy < spec.Height; dy++ {
+		y0 := sb.Min.Y + dy*sh/spec.Height
+		y1 := max(sb.Min.Y+(dy+1)*sh/spec.Height, y0+1)
+		for dx := 0; dx < spec.Width; dx++ {
+			x0 := sb.Min.X + dx*sw/spec.Width
+			x1 := max(sb.Min.X+(dx+1)*sw/spec.Width, x0+1)
+			var r, g, b, a, n uint32
+			for y := y0; y < y1; y++ {
+				row := src.PixOffset(x0, y)
+				for x := x0; x < x1; x++ {
+					r += uint32(src.Pix[row])
+					g += uint32(src.Pix[row+1])
+					b += uint32(src.Pix[row+2])
+					a += uint32(src.Pix[row+3])
+					row += 4
+					n++
+				}
+			}
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
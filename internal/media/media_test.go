@@ -0,0 +1,120 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 50, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessCropsAndScalesToSpec(t *testing.T) {
+	for _, size := range [][2]int{{1000, 300}, {120, 900}, {50, 50}} {
+		out, err := Process(bytes.NewReader(encodePNG(t, size[0], size[1])), Avatar)
+		if err != nil {
+			t.Fatalf("Failed to process %dx%d image: %v", size[0], size[1], err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("Failed to decode output: %v", err)
+		}
+		if b := img.Bounds(); b.Dx() != Avatar.Width || b.Dy() != Avatar.Height {
+			t.Errorf("Expected %dx%d output, got %dx%d", Avatar.Width, Avatar.Height, b.Dx(), b.Dy())
+		}
+		r, g, _, _ := img.At(200, 200).RGBA()
+		if r>>8 < 180 || g>>8 > 80 {
+			t.Errorf("Expected colour to survive scaling, got r=%d g=%d", r>>8, g>>8)
+		}
+	}
+}
+
+func TestProcessTinyImages(t *testing.T) {
+	for _, spec := range []Spec{Avatar, Banner} {
+		for _, size := range [][2]int{{1, 1}, {2, 1}, {1, 2}} {
+			out, err := Process(bytes.NewReader(encodePNG(t, size[0], size[1])), spec)
+			if err != nil {
+				t.Fatalf("Failed to process %dx%d image for %dx%d: %v", size[0], size[1], spec.Width, spec.Height, err)
+			}
+			img, err := jpeg.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("Failed to decode output: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != spec.Width || b.Dy() != spec.Height {
+				t.Errorf("Expected %dx%d output, got %dx%d", spec.Width, spec.Height, b.Dx(), b.Dy())
+			}
+		}
+	}
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+	if _, err := Process(strings.NewReader("not an image"), Avatar); err != ErrUnsupportedImage {
+		t.Errorf("Expected ErrUnsupportedImage, got %v", err)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStorage(dir, "http://localhost:8080/media/")
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "avatars/a.jpg", []byte("data"), "image/jpeg"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "avatars", "a.jpg"))
+	if err != nil || string(got) != "data" {
+		t.Fatalf("Failed to read stored file: %q, %v", got, err)
+	}
+	if url := store.URL("avatars/a.jpg"); url != "http://localhost:8080/media/avatars/a.jpg" {
+		t.Errorf("Unexpected URL %q", url)
+	}
+	if err := store.Delete(ctx, "avatars/a.jpg"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if err := store.Delete(ctx, "avatars/a.jpg"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, got %v", err)
+	}
+	for _, key := range []string{"../escape", "/abs", "a/../../b", ""} {
+		if err := store.Put(ctx, key, nil, ""); err != ErrInvalidKey {
+			t.Errorf("Expected ErrInvalidKey for %q, got %v", key, err)
+		}
+	}
+}
+
+func TestFileSystemHidesDirectories(t *testing.T) {
+	dir := t.TempDir()
+	store := NewLocalStorage(dir, "http://localhost:8080/media")
+	if err := store.Put(context.Background(), "avatars/a.jpg", []byte("data"), "image/jpeg"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	server := http.FileServer(FileSystem(dir))
+
+	for path, want := range map[string]int{"/avatars/a.jpg": http.StatusOK, "/avatars/": http.StatusNotFound, "/": http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
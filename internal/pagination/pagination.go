@@ -0,0 +1,147 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("invalid limit")
+)
+
+// Cursor marks a position in a list ordered by (time, id) descending. The
+// id breaks ties between rows created in the same instant.
+type Cursor struct {
+	Time time.Time
+	ID   uuid.UUID
+}
+
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.Time.UnixMicro(), 10) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode parses a cursor produced by Encode. An empty string yields a nil
+// cursor, meaning the first page.
+func Decode(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	micros, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+	usec, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{Time: time.UnixMicro(usec).UTC(), ID: parsedID}, nil
+}
+
+// RankCursor marks a position in a list ordered by (rank, id) descending,
+// where rank is a stored count such as a user's followers.
+type RankCursor struct {
+	Rank int64
+	ID   uuid.UUID
+}
+
+func (c RankCursor) Encode() string {
+	raw := strconv.FormatInt(c.Rank, 10) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeRank parses a cursor produced by RankCursor.Encode. An empty string
+// yields a nil cursor, meaning the first page.
+func DecodeRank(s string) (*RankCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	rank, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+	parsedRank, err := strconv.ParseInt(rank, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &RankCursor{Rank: parsedRank, ID: parsedID}, nil
+}
+
+// ScoreCursor marks a position in a list ordered by (score, id) descending,
+// where score is a computed ranking that need not be a whole number.
+type ScoreCursor struct {
+	Score float64
+	ID    uuid.UUID
+}
+
+func (c ScoreCursor) Encode() string {
+	raw := strconv.FormatFloat(c.Score, 'g', -1, 64) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeScore parses a cursor produced by ScoreCursor.Encode. An empty
+// string yields a nil cursor, meaning the first page.
+func DecodeScore(s string) (*ScoreCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	score, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+	parsedScore, err := strconv.ParseFloat(score, 64)
+	if err != nil || math.IsNaN(parsedScore) || math.IsInf(parsedScore, 0) {
+		return nil, ErrInvalidCursor
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &ScoreCursor{Score: parsedScore, ID: parsedID}, nil
+}
+
+func ParseLimit(s string) (int32, error) {
+	if s == "" {
+		return DefaultLimit, nil
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 {
+		return 0, ErrInvalidLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	return int32(limit), nil
+}
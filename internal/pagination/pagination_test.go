@@ -0,0 +1,83 @@
+package pagination
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{Time: time.Date(2025, 8, 1, 12, 30, 0, 123456000, time.UTC), ID: uuid.New()}
+
+	decoded, err := Decode(cursor.Encode())
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if !decoded.Time.Equal(cursor.Time) || decoded.ID != cursor.ID {
+		t.Errorf("Expected %+v, got %+v", cursor, decoded)
+	}
+}
+
+func TestDecodeEmptyCursor(t *testing.T) {
+	cursor, err := Decode("")
+	if err != nil || cursor != nil {
+		t.Errorf("Expected nil cursor for empty string, got %+v, %v", cursor, err)
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	for _, s := range []string{"not base64!", "bm8tc2VwYXJhdG9y", "MTIzfG5vdC1hLXV1aWQ"} {
+		if _, err := Decode(s); err != ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", s, err)
+		}
+	}
+}
+
+func TestRankCursorRoundTrip(t *testing.T) {
+	cursor := RankCursor{Rank: 1234, ID: uuid.New()}
+
+	decoded, err := DecodeRank(cursor.Encode())
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if *decoded != cursor {
+		t.Errorf("Expected %+v, got %+v", cursor, decoded)
+	}
+
+	if _, err := DecodeRank("bm8tc2VwYXJhdG9y"); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestScoreCursorRoundTrip(t *testing.T) {
+	cursor := ScoreCursor{Score: 4321.123456789012, ID: uuid.New()}
+
+	decoded, err := DecodeScore(cursor.Encode())
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if *decoded != cursor {
+		t.Errorf("Expected %+v, got %+v", cursor, decoded)
+	}
+
+	if _, err := DecodeScore(ScoreCursor{Score: math.NaN(), ID: uuid.New()}.Encode()); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := map[string]int32{"": DefaultLimit, "5": 5, "1000": MaxLimit}
+	for input, want := range tests {
+		got, err := ParseLimit(input)
+		if err != nil || got != want {
+			t.Errorf("ParseLimit(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"0", "-1", "ten"} {
+		if _, err := ParseLimit(input); err != ErrInvalidLimit {
+			t.Errorf("Expected ErrInvalidLimit for %q, got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const prefixLength = 5
+
+// Corpus looks up passwords in a local copy of a breached-password list in
+// the Pwned Passwords format: one upper- or lowercase SHA-1 hash per line,
+// optionally followed by ":count", sorted by hash. Opening it indexes where
+// each 5-character hash prefix starts, so a lookup reads only the lines
+// sharing the password's prefix, the same k-anonymity buckets the online
+// range API serves, and the file never has to fit in memory.
+type Corpus struct {
+	f       *os.File
+	buckets map[string]span
+}
+
+type span struct {
+	start, end int64
+}
+
+func OpenCorpus(path string) (*Corpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Corpus{f: f, buckets: make(map[string]span)}
+	if err := c.index(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("indexing %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *Corpus) index() error {
+	reader := bufio.NewReader(c.f)
+	var offset int64
+	var prev string
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			hash := strings.ToUpper(strings.TrimSpace(line))
+			hash, _, _ = strings.Cut(hash, ":")
+			if hash != "" {
+				if len(hash) != 2*sha1.Size {
+					return fmt.Errorf("line %d: not a SHA-1 hash", lineNo)
+				}
+				if hash < prev {
+					return fmt.Errorf("line %d: hashes are not sorted", lineNo)
+				}
+				prev = hash
+				prefix := hash[:prefixLength]
+				s, ok := c.buckets[prefix]
+				if !ok {
+					s.start = offset
+				}
+				s.end = offset + int64(len(line))
+				c.buckets[prefix] = s
+			}
+			offset += int64(len(line))
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Count returns how often the password appears in the corpus, or 0 if it
+// doesn't. Lines without a count count once.
+func (c *Corpus) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	s, ok := c.buckets[hash[:prefixLength]]
+	if !ok {
+		return 0, nil
+	}
+	bucket := make([]byte, s.end-s.start)
+	if _, err := c.f.ReadAt(bucket, s.start); err != nil {
+		return 0, err
+	}
+	for _, line := range bytes.Split(bucket, []byte("\n")) {
+		entry, count, hasCount := strings.Cut(strings.TrimSpace(string(line)), ":")
+		if !strings.EqualFold(entry, hash) {
+			continue
+		}
+		if !hasCount {
+			return 1, nil
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("bad count for %s: %w", hash, err)
+		}
+		return n, nil
+	}
+	return 0, nil
+}
+
+func (c *Corpus) Close() error {
+	return c.f.Close()
+}
@@ -0,0 +1,123 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func writeCorpus(t *testing.T, lines ...string) string {
+	t.Helper()
+	sort.Strings(lines)
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write corpus: %v", err)
+	}
+	return path
+}
+
+func rules(violations []Violation) []string {
+	var names []string
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func TestCheckReportsEveryRule(t *testing.T) {
+	corpus, err := OpenCorpus(writeCorpus(t, sha1Hex("qwerty")+":3730471"))
+	if err != nil {
+		t.Fatalf("Failed to open corpus: %v", err)
+	}
+	defer corpus.Close()
+	policy := DefaultPolicy()
+	policy.Breached = corpus
+
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"", "required"},
+		{"qwerty", "min_length,common,breached"},
+		{"Password123", "common"},
+		{strings.Repeat("a", 73), "max_length"},
+		{"correct horse battery staple", ""},
+	}
+	for _, tt := range tests {
+		violations, err := policy.Check(tt.password)
+		if err != nil {
+			t.Fatalf("Check(%q) failed: %v", tt.password, err)
+		}
+		if got := strings.Join(rules(violations), ","); got != tt.want {
+			t.Errorf("Check(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestCorpusCount(t *testing.T) {
+	// The neighbour shares hunter2's prefix, so the lookup has to search
+	// the bucket rather than take its first line.
+	target := sha1Hex("hunter2")
+	neighbour := target[:5] + strings.Repeat("0", 35)
+	path := writeCorpus(t,
+		strings.ToLower(target)+":17",
+		neighbour,
+		sha1Hex("letmein")+":2",
+	)
+	corpus, err := OpenCorpus(path)
+	if err != nil {
+		t.Fatalf("Failed to open corpus: %v", err)
+	}
+	defer corpus.Close()
+
+	for password, want := range map[string]int{"hunter2": 17, "letmein": 2, "not in the corpus": 0} {
+		got, err := corpus.Count(password)
+		if err != nil {
+			t.Fatalf("Count(%q) failed: %v", password, err)
+		}
+		if got != want {
+			t.Errorf("Count(%q) = %d, want %d", password, got, want)
+		}
+	}
+}
+
+func TestOpenCorpusRejectsUnsortedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	data := sha1Hex("b") + "\n" + sha1Hex("a") + "\n"
+	if sha1Hex("a") > sha1Hex("b") {
+		data = sha1Hex("a") + "\n" + sha1Hex("b") + "\n"
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write corpus: %v", err)
+	}
+	if _, err := OpenCorpus(path); err == nil {
+		t.Errorf("Expected unsorted corpus to be rejected")
+	}
+}
+
+func TestLoadBanned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(path, []byte("# site names\nChirpyRocks\n\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write banned list: %v", err)
+	}
+	policy := DefaultPolicy()
+	if err := policy.LoadBanned(path); err != nil {
+		t.Fatalf("Failed to load banned list: %v", err)
+	}
+	violations, err := policy.Check("chirpyrocks")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if got := strings.Join(rules(violations), ","); got != "common" {
+		t.Errorf("Expected common, got %q", got)
+	}
+}
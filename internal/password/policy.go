@@ -0,0 +1,116 @@
+package password
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxBcryptLength is the most bcrypt will hash; longer passwords are
+// rejected by auth.HashPassword.
+const MaxBcryptLength = 72
+
+// commonPasswords are refused even without a banned list or breach corpus.
+var commonPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "password", "password1",
+	"password123", "qwerty", "qwerty123", "qwertyuiop", "abc123", "111111",
+	"000000", "iloveyou", "letmein", "welcome", "monkey", "dragon",
+	"football", "baseball", "sunshine", "princess", "admin", "admin123",
+	"passw0rd", "trustno1", "superman", "starwars", "whatever", "chirpy",
+	"chirpy123",
+}
+
+// Violation is one rule a password failed.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Policy decides which passwords are acceptable. The zero value only
+// refuses empty passwords and ones bcrypt can't hash.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// Banned holds lowercased passwords that are refused outright.
+	Banned map[string]struct{}
+	// Breached, if set, refuses passwords that appear in a breach corpus.
+	Breached *Corpus
+}
+
+// DefaultPolicy requires 8 characters and refuses the built-in list of
+// common passwords.
+func DefaultPolicy() *Policy {
+	p := &Policy{MinLength: 8, MaxLength: MaxBcryptLength, Banned: make(map[string]struct{})}
+	for _, pw := range commonPasswords {
+		p.Banned[pw] = struct{}{}
+	}
+	return p
+}
+
+// LoadBanned adds the passwords in a file, one per line, to the banned
+// list. Blank lines and lines starting with # are skipped.
+func (p *Policy) LoadBanned(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if p.Banned == nil {
+		p.Banned = make(map[string]struct{})
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.Banned[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Check returns every rule the password breaks, or nil if it is
+// acceptable. An error means the breach corpus couldn't be read.
+func (p *Policy) Check(password string) ([]Violation, error) {
+	var violations []Violation
+	if password == "" {
+		return []Violation{{Rule: "required", Message: "is required"}}, nil
+	}
+	if length := utf8.RuneCountInString(password); length < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    "min_length",
+			Message: fmt.Sprintf("must be at least %d characters", p.MinLength),
+		})
+	}
+	maxLength := p.MaxLength
+	if maxLength <= 0 || maxLength > MaxBcryptLength {
+		maxLength = MaxBcryptLength
+	}
+	if len(password) > maxLength {
+		violations = append(violations, Violation{
+			Rule:    "max_length",
+			Message: fmt.Sprintf("must be at most %d bytes", maxLength),
+		})
+	}
+	if _, ok := p.Banned[strings.ToLower(password)]; ok {
+		violations = append(violations, Violation{
+			Rule:    "common",
+			Message: "is too common",
+		})
+	}
+	if p.Breached != nil {
+		count, err := p.Breached.Count(password)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			violations = append(violations, Violation{
+				Rule:    "breached",
+				Message: "has appeared in a data breach",
+			})
+		}
+	}
+	return violations, nil
+}
@@ -0,0 +1,122 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventChirp        = "chirp"
+	EventDelete       = "delete"
+	EventNotification = "notification"
+)
+
+// Event is a single published change. ThreadID is set for chirp and delete
+// events; RecipientID is set for notifications, which are private to one
+// user.
+type Event struct {
+	ID          uint64
+	Type        string
+	AuthorID    uuid.UUID
+	ThreadID    uuid.UUID
+	RecipientID uuid.UUID
+	Data        any
+}
+
+func (e Event) IsTimeline() bool {
+	return e.Type == EventChirp || e.Type == EventDelete
+}
+
+type Filter func(Event) bool
+
+// Subscription delivers events published after it was created. Replay holds
+// any retained events newer than the requested Last-Event-ID. C is closed
+// when the subscriber falls too far behind and is dropped.
+type Subscription struct {
+	Replay []Event
+	C      <-chan Event
+	ch     chan Event
+	filter Filter
+}
+
+// Broker fans published events out to subscribers. It keeps a bounded
+// history so reconnecting clients can resume, and never blocks publishers on
+// slow subscribers: a subscriber whose buffer is full is disconnected.
+type Broker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	historySize int
+	bufferSize  int
+	subs        map[*Subscription]struct{}
+}
+
+func NewBroker(historySize, bufferSize int) *Broker {
+	return &Broker{
+		nextID:      1,
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subs:        make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *Broker) Publish(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event.ID = b.nextID
+	b.nextID++
+	if b.historySize > 0 {
+		if len(b.history) == b.historySize {
+			copy(b.history, b.history[1:])
+			b.history = b.history[:len(b.history)-1]
+		}
+		b.history = append(b.history, event)
+	}
+
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a subscriber. Events retained in history with an ID
+// greater than lastEventID are returned in Replay; pass 0 to skip replay.
+func (b *Broker) Subscribe(lastEventID uint64, filter Filter) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, b.bufferSize)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	if lastEventID > 0 {
+		for _, event := range b.history {
+			if event.ID <= lastEventID {
+				continue
+			}
+			if filter == nil || filter(event) {
+				sub.Replay = append(sub.Replay, event)
+			}
+		}
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublishFiltersByAuthor(t *testing.T) {
+	broker := NewBroker(10, 10)
+	author := uuid.New()
+	sub := broker.Subscribe(0, func(e Event) bool { return e.AuthorID == author })
+	defer broker.Unsubscribe(sub)
+
+	broker.Publish(Event{Type: EventChirp, AuthorID: uuid.New()})
+	broker.Publish(Event{Type: EventChirp, AuthorID: author})
+
+	select {
+	case event := <-sub.C:
+		if event.AuthorID != author {
+			t.Errorf("Expected event from %v, got %v", author, event.AuthorID)
+		}
+	default:
+		t.Fatalf("Expected an event to be delivered")
+	}
+	select {
+	case event := <-sub.C:
+		t.Errorf("Expected no more events, got %+v", event)
+	default:
+	}
+}
+
+func TestSubscribeReplaysFromLastEventID(t *testing.T) {
+	broker := NewBroker(3, 10)
+	for i := 0; i < 5; i++ {
+		broker.Publish(Event{Type: EventChirp})
+	}
+
+	sub := broker.Subscribe(3, nil)
+	defer broker.Unsubscribe(sub)
+	if len(sub.Replay) != 2 {
+		t.Fatalf("Expected 2 replayed events, got %d", len(sub.Replay))
+	}
+	if sub.Replay[0].ID != 4 || sub.Replay[1].ID != 5 {
+		t.Errorf("Expected events 4 and 5, got %d and %d", sub.Replay[0].ID, sub.Replay[1].ID)
+	}
+
+	// Only the last three events are retained.
+	sub2 := broker.Subscribe(1, nil)
+	defer broker.Unsubscribe(sub2)
+	if len(sub2.Replay) != 3 || sub2.Replay[0].ID != 3 {
+		t.Errorf("Expected replay to start at retained event 3, got %+v", sub2.Replay)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	broker := NewBroker(0, 2)
+	sub := broker.Subscribe(0, nil)
+
+	for i := 0; i < 3; i++ {
+		broker.Publish(Event{Type: EventChirp})
+	}
+
+	received := 0
+	for range sub.C {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("Expected 2 buffered events before drop, got %d", received)
+	}
+
+	// Unsubscribing a dropped subscriber must not panic.
+	broker.Unsubscribe(sub)
+}
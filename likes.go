@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+func (c *apiConfig) LikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+	chirp, err := c.dbQueries.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+		log.Printf("Error retrieving chirp: %v", err)
+		return
+	}
+
+	blocked, err := c.isBlockedEitherWay(r.Context(), userID, chirp.UserID)
+	if err != nil {
+		respondWithError(w, "Failed to like chirp", http.StatusInternalServerError)
+		log.Printf("Error checking blocks: %v", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, "You cannot like this chirp", http.StatusForbidden)
+		return
+	}
+
+	added, err := c.dbQueries.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to like chirp", http.StatusInternalServerError)
+		log.Printf("Error liking chirp: %v", err)
+		return
+	}
+	if added > 0 {
+		c.publishNotification(chirp.UserID, notification{Type: "like", FromUserID: userID, ChirpID: chirpID})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *apiConfig) UnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+
+	err = c.dbQueries.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to unlike chirp", http.StatusInternalServerError)
+		log.Printf("Error unliking chirp: %v", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,54 +1,262 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/tbirddv/chirpy/internal/activitypub"
+	"github.com/tbirddv/chirpy/internal/analytics"
+	"github.com/tbirddv/chirpy/internal/auth"
 	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/mail"
+	"github.com/tbirddv/chirpy/internal/media"
+	"github.com/tbirddv/chirpy/internal/password"
+	"github.com/tbirddv/chirpy/internal/stream"
 )
 
+// shutdownTimeout bounds how long a shutdown waits for requests in flight.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	godotenv.Load()
 	dbURL := os.Getenv("DB_URL")
 	platform := os.Getenv("PLATFORM")
 	tokenSecret := os.Getenv("TOKENSECRET")
 	polkaKey := os.Getenv("POLKA_KEY")
+	baseURL := strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	exportDir := os.Getenv("EXPORT_DIR")
+	if exportDir == "" {
+		exportDir = "exports"
+	}
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "Chirpy <noreply@localhost>"
+	}
+	// Without an SMTP server, mail is written to MAIL_DIR or the log.
+	var mailer mail.Mailer = &mail.FileMailer{Dir: os.Getenv("MAIL_DIR"), From: mailFrom}
+	if smtpAddr := os.Getenv("SMTP_ADDR"); smtpAddr != "" {
+		mailer = &mail.SMTPMailer{
+			Addr:     smtpAddr,
+			From:     mailFrom,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+	}
+	requireVerifiedEmail, _ := strconv.ParseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION"))
+	deletionGrace := 30 * 24 * time.Hour
+	if value := os.Getenv("ACCOUNT_DELETION_GRACE"); value != "" {
+		grace, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid ACCOUNT_DELETION_GRACE: %v", err)
+		}
+		deletionGrace = grace
+	}
+
+	// Sampling one view in VIEW_SAMPLE_RATE keeps hot chirps from
+	// serialising on the counter's lock; sampled views are weighted so
+	// totals stay right on average. Set it to 1 to count every view.
+	viewSampleRate := 10
+	if value := os.Getenv("VIEW_SAMPLE_RATE"); value != "" {
+		rate, err := strconv.Atoi(value)
+		if err != nil || rate < 1 {
+			log.Fatalf("Invalid VIEW_SAMPLE_RATE %q: must be a positive integer", value)
+		}
+		viewSampleRate = rate
+	}
+
+	var err error
+	passwordPolicy := password.DefaultPolicy()
+	if value := os.Getenv("PASSWORD_MIN_LENGTH"); value != "" {
+		passwordPolicy.MinLength, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("Invalid PASSWORD_MIN_LENGTH: %v", err)
+		}
+	}
+	if path := os.Getenv("PASSWORD_BANNED_FILE"); path != "" {
+		if err := passwordPolicy.LoadBanned(path); err != nil {
+			log.Fatalf("Error loading banned passwords: %v", err)
+		}
+	}
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		passwordPolicy.Breached, err = password.OpenCorpus(path)
+		if err != nil {
+			log.Fatalf("Error opening breached password corpus: %v", err)
+		}
+		defer passwordPolicy.Breached.Close()
+	}
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatal(err)
 	}
-	config := &apiConfig{dbQueries: database.New(db), platform: platform, tokenSecret: tokenSecret, polkaKey: polkaKey}
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), database.New(db), os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// Listening before anything starts means a taken port fails straight
+	// away rather than after work is queued that would then be lost.
+	listener, err := net.Listen("tcp", ":8080")
+	if err != nil {
+		log.Fatal(err)
+	}
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	federation := activitypub.NewQueue(httpClient, activitypub.QueueOptions{})
+	defer federation.Close()
 
-	handler := http.NewServeMux()
-	server := &http.Server{
-		Addr:    ":8080",
-		Handler: handler,
+	config := &apiConfig{
+		db:          db,
+		dbQueries:   database.New(db),
+		platform:    platform,
+		tokenSecret: tokenSecret,
+		polkaKey:    polkaKey,
+		baseURL:     baseURL,
+		httpClient:  httpClient,
+		federation:  federation,
+		events:      stream.NewBroker(1000, 64),
+		media:       media.NewLocalStorage(mediaDir, baseURL+"/media"),
+		mailer:      mailer,
+
+		requireVerifiedEmail: requireVerifiedEmail,
+		deletionGrace:        deletionGrace,
+		passwordPolicy:       passwordPolicy,
+		exportDir:            exportDir,
 	}
+	config.views = analytics.NewViewCounter(config.flushViews, analytics.Options{SampleRate: viewSampleRate})
+	defer config.views.Close()
+
+	config.failInterruptedExports(context.Background())
+
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	go config.sweepExpiredChirps(sweepCtx)
+	go config.purgeDeletedAccounts(sweepCtx)
+	go config.cleanupExports(sweepCtx)
+
+	handler := http.NewServeMux()
+	server := &http.Server{Handler: handler}
+	stopping := make(chan struct{})
+	config.stopping = stopping
+	server.RegisterOnShutdown(func() { close(stopping) })
 
 	handler.Handle("/app/", config.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
 
+	handler.Handle("GET /media/", http.StripPrefix("/media", http.FileServer(media.FileSystem(mediaDir))))
+
 	handler.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.Write([]byte("OK"))
 	})
 
-	handler.HandleFunc("GET /admin/metrics", config.writeMetrics)
-	handler.HandleFunc("POST /admin/reset", config.resetMetrics)
+	administer := func(next http.HandlerFunc) http.HandlerFunc {
+		return config.requirePermission(auth.PermAdminister, next)
+	}
+	moderate := func(next http.HandlerFunc) http.HandlerFunc {
+		return config.requirePermission(auth.PermModerate, next)
+	}
+	handler.HandleFunc("GET /admin/metrics", administer(config.writeMetrics))
+	handler.HandleFunc("POST /admin/reset", administer(config.resetMetrics))
+	handler.HandleFunc("PUT /admin/users/{id}/role", administer(config.SetUserRole))
+	handler.HandleFunc("GET /admin/banned-domains", administer(config.GetBannedDomains))
+	handler.HandleFunc("POST /admin/banned-domains", administer(config.BanDomain))
+	handler.HandleFunc("DELETE /admin/banned-domains/{domain}", administer(config.UnbanDomain))
+	handler.HandleFunc("PUT /admin/chirps/{id}/content-warning", moderate(config.SetContentWarning))
+	handler.HandleFunc("PUT /admin/users/{id}/suspension", moderate(config.SuspendUser))
+	handler.HandleFunc("DELETE /admin/users/{id}/suspension", moderate(config.UnsuspendUser))
 	handler.HandleFunc("POST /api/chirps", config.CreateChirp)
 	handler.HandleFunc("GET /api/chirps", config.GetChirps)
+	handler.HandleFunc("GET /api/chirps/stream", config.StreamChirps)
+	handler.HandleFunc("GET /api/ws", config.HandleWebSocket)
+	handler.HandleFunc("POST /api/ws/ticket", config.IssueWebSocketTicket)
 	handler.HandleFunc("GET /api/chirps/{id}", config.GetChirpByID)
+	handler.HandleFunc("POST /api/chirps/{id}/like", config.LikeChirp)
+	handler.HandleFunc("DELETE /api/chirps/{id}/like", config.UnlikeChirp)
+	handler.HandleFunc("POST /api/chirps/{id}/rechirp", config.Rechirp)
+	handler.HandleFunc("DELETE /api/chirps/{id}/rechirp", config.UndoRechirp)
 	handler.HandleFunc("POST /api/users", config.createUser)
 	handler.HandleFunc("POST /api/login", config.HandleLogin)
 	handler.HandleFunc("POST /api/refresh", config.HandleRefresh)
 	handler.HandleFunc("POST /api/revoke", config.HandleRevoke)
-	handler.HandleFunc("PUT /api/users", config.updateUser)
+	handler.HandleFunc("GET /api/sessions", config.ListSessions)
+	handler.HandleFunc("DELETE /api/sessions/{id}", config.RevokeSession)
+	handler.HandleFunc("POST /api/sessions/revoke-all", config.RevokeOtherSessions)
+	handler.HandleFunc("POST /api/password/forgot", config.forgotPassword)
+	handler.HandleFunc("POST /api/password/reset", config.resetPassword)
+	handler.HandleFunc("PUT /api/users", deprecated("/api/users/me", config.replaceUser))
+	handler.HandleFunc("POST /api/users/verify", config.verifyEmail)
+	handler.HandleFunc("POST /api/users/verify/resend", config.resendVerification)
+	handler.HandleFunc("GET /api/users", config.SearchUsers)
+	handler.HandleFunc("GET /api/users/{handle}", config.GetProfile)
+	handler.HandleFunc("PUT /api/users/me/preferences", config.updatePreferences)
+	handler.HandleFunc("GET /api/users/me", config.getCurrentUser)
+	handler.HandleFunc("PATCH /api/users/me", config.updateUser)
+	handler.HandleFunc("DELETE /api/users/me", config.deleteAccount)
+	handler.HandleFunc("PUT /api/users/me/avatar", config.uploadAvatar)
+	handler.HandleFunc("DELETE /api/users/me/avatar", config.deleteAvatar)
+	handler.HandleFunc("PUT /api/users/me/banner", config.uploadBanner)
+	handler.HandleFunc("DELETE /api/users/me/banner", config.deleteBanner)
 	handler.HandleFunc("DELETE /api/chirps/{id}", config.DeleteChirp)
 	handler.HandleFunc("POST /api/polka/webhooks", config.GiveChirpyRed)
+	handler.HandleFunc("POST /api/users/{id}/follow", config.FollowUser)
+	handler.HandleFunc("DELETE /api/users/{id}/follow", config.UnfollowUser)
+	handler.HandleFunc("GET /api/users/{id}/followers", config.GetFollowers)
+	handler.HandleFunc("GET /api/users/{id}/following", config.GetFollowing)
+	handler.HandleFunc("GET /api/timeline/home", config.GetHomeTimeline)
+	handler.HandleFunc("GET /api/users/me/blocks", config.GetBlocks)
+	handler.HandleFunc("POST /api/users/me/blocks", config.BlockUser)
+	handler.HandleFunc("DELETE /api/users/me/blocks/{id}", config.UnblockUser)
+	handler.HandleFunc("GET /api/users/me/mutes", config.GetMutes)
+	handler.HandleFunc("POST /api/users/me/mutes", config.MuteUser)
+	handler.HandleFunc("DELETE /api/users/me/mutes/{id}", config.UnmuteUser)
+	handler.HandleFunc("GET /api/users/me/analytics", config.GetAnalytics)
+	handler.HandleFunc("POST /api/users/me/export", config.RequestExport)
+	handler.HandleFunc("GET /api/users/me/exports/{id}", config.GetExport)
+	handler.HandleFunc("GET /api/exports/{id}/download", config.DownloadExport)
+
+	handler.HandleFunc("GET /.well-known/webfinger", config.HandleWebFinger)
+	handler.HandleFunc("GET /users/{id}", config.GetActor)
+	handler.HandleFunc("GET /users/{id}/outbox", config.GetOutbox)
+	handler.HandleFunc("GET /users/{id}/followers", config.GetFollowersCollection)
+	handler.HandleFunc("POST /users/{id}/inbox", config.HandleInbox)
 
-	log.Fatal(server.ListenAndServe())
+	// On SIGINT or SIGTERM, stop accepting connections and let requests in
+	// flight finish; returning from main then runs the deferred closers,
+	// which flush view counts and stop the sweepers and delivery workers.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		log.Printf("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down: %v", err)
+		}
+	}()
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		log.Printf("Error serving: %v", err)
+		return
+	}
+	<-shutdownDone
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+// SetContentWarning lets moderators put a content warning or the sensitive
+// flag on any chirp, or clear them, without deleting it.
+func (c *apiConfig) SetContentWarning(w http.ResponseWriter, r *http.Request) {
+	callerID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+	var p contentWarningParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, "Failed to decode content warning", http.StatusBadRequest)
+		return
+	}
+	cw, ok := contentWarning(w, p.ContentWarning)
+	if !ok {
+		return
+	}
+
+	tx, err := c.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, "Failed to update chirp", http.StatusInternalServerError)
+		log.Printf("Error starting transaction: %v", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
+
+	chirp, err := qtx.SetChirpContentWarning(r.Context(), database.SetChirpContentWarningParams{
+		ID:             chirpID,
+		ContentWarning: cw,
+		Sensitive:      p.Sensitive,
+	})
+	if err == nil {
+		err = recordAudit(r.Context(), qtx, database.CreateAuditEntryParams{
+			ActorID:   actor(callerID),
+			SubjectID: chirp.UserID,
+			Action:    auditSetContentWarning,
+			ChirpID:   uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		}, auditContentWarning{ContentWarning: cw.String, Sensitive: p.Sensitive})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to update chirp", http.StatusInternalServerError)
+		log.Printf("Error setting content warning: %v", err)
+		return
+	}
+	c.federateChirp(r.Context(), chirp, "Update")
+
+	JSONChirp, err := createResponseStruct(chirp)
+	if err != nil {
+		respondWithError(w, "Failed to create chirp response", http.StatusInternalServerError)
+		log.Printf("Error creating chirp response: %v", err)
+		return
+	}
+	respondWithJSON(w, JSONChirp, http.StatusOK)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/mail"
+)
+
+const passwordResetTTL = time.Hour
+
+// forgotPassword emails a reset token if the address belongs to a user. It
+// answers 202 either way so it can't be used to find out who has an account.
+func (c *apiConfig) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	var p forgotPasswordParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.dbQueries.GetUserByEmail(r.Context(), p.Email)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		respondWithError(w, "Failed to start password reset", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+
+	// Only the hash is stored; the token itself exists solely in the email.
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, "Failed to start password reset", http.StatusInternalServerError)
+		log.Printf("Error creating reset token: %v", err)
+		return
+	}
+	err = c.dbQueries.CreatePasswordResetToken(r.Context(), database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		respondWithError(w, "Failed to start password reset", http.StatusInternalServerError)
+		log.Printf("Error storing reset token: %v", err)
+		return
+	}
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Hi @%s,\n\n"+
+			"Someone asked to reset your password. To choose a new one, send this token\n"+
+			"with your new password to POST %s/api/password/reset:\n\n"+
+			"%s\n\n"+
+			"The token works once and expires in an hour. If you didn't ask for this,\n"+
+			"ignore this email; your password hasn't changed.\n",
+			user.Handle, c.baseURL, token),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := c.mailer.Send(ctx, msg); err != nil {
+			log.Printf("Error sending password reset email: %v", err)
+		}
+	}()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resetPassword sets a new password with a token from forgotPassword. The
+// token, any others outstanding for the user and all of the user's refresh
+// tokens are invalidated with the change, so a stolen session doesn't
+// survive a reset.
+func (c *apiConfig) resetPassword(w http.ResponseWriter, r *http.Request) {
+	var p resetPasswordParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	fieldErrors := make(map[string]string)
+	rules, err := c.checkPassword(p.Password, fieldErrors)
+	if err != nil {
+		respondWithError(w, "Failed to reset password", http.StatusInternalServerError)
+		log.Printf("Error checking password: %v", err)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		respondWithPasswordErrors(w, "Invalid password", fieldErrors, rules)
+		return
+	}
+	hashedPassword, err := auth.HashPassword(p.Password)
+	if err != nil {
+		respondWithError(w, "Failed to reset password", http.StatusInternalServerError)
+		log.Printf("Error hashing password: %v", err)
+		return
+	}
+
+	tx, err := c.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, "Failed to reset password", http.StatusInternalServerError)
+		log.Printf("Error starting transaction: %v", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
+
+	userID, err := qtx.ConsumePasswordResetToken(r.Context(), auth.HashToken(p.Token))
+	if err == sql.ErrNoRows {
+		respondWithError(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		respondWithError(w, "Failed to reset password", http.StatusInternalServerError)
+		log.Printf("Error consuming reset token: %v", err)
+		return
+	}
+	err = qtx.UpdatePassword(r.Context(), database.UpdatePasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPassword,
+	})
+	if err == nil {
+		err = qtx.DeletePasswordResetTokens(r.Context(), userID)
+	}
+	if err == nil {
+		err = qtx.RevokeAllRefreshTokens(r.Context(), userID)
+	}
+	if err != nil {
+		respondWithError(w, "Failed to reset password", http.StatusInternalServerError)
+		log.Printf("Error resetting password: %v", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, "Failed to reset password", http.StatusInternalServerError)
+		log.Printf("Error committing password reset: %v", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/media"
+)
+
+const maxImageUploadBytes = 5 << 20
+
+var profileFieldLimits = map[string]int{
+	"display_name": 50,
+	"bio":          160,
+	"location":     30,
+	"website":      100,
+}
+
+func (c *apiConfig) mediaURL(key sql.NullString) string {
+	if !key.Valid {
+		return ""
+	}
+	return c.media.URL(key.String)
+}
+
+// userResponse is createResponseStruct for users plus the links to their
+// images, which depend on the storage backend.
+func (c *apiConfig) userResponse(user database.User) (any, error) {
+	resp, err := createResponseStruct(user)
+	if err != nil {
+		return nil, err
+	}
+	u := resp.(User)
+	u.AvatarURL = c.mediaURL(user.AvatarKey)
+	u.BannerURL = c.mediaURL(user.BannerKey)
+	return u, nil
+}
+
+func wantsAuthor(r *http.Request) bool {
+	for _, value := range r.URL.Query()["expand"] {
+		for _, field := range strings.Split(value, ",") {
+			if strings.TrimSpace(field) == "author" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// attachAuthors embeds each chirp's author, loading all of them in one query.
+// It returns when the most recently changed author was last updated, since
+// that changes the response as much as editing a chirp does.
+func (c *apiConfig) attachAuthors(ctx context.Context, chirps []Chirp) (time.Time, error) {
+	var lastModified time.Time
+	if len(chirps) == 0 {
+		return lastModified, nil
+	}
+	seen := make(map[uuid.UUID]struct{})
+	var ids []uuid.UUID
+	for _, chirp := range chirps {
+		if _, ok := seen[chirp.UserID]; !ok {
+			seen[chirp.UserID] = struct{}{}
+			ids = append(ids, chirp.UserID)
+		}
+	}
+	users, err := c.dbQueries.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return lastModified, err
+	}
+	authors := make(map[uuid.UUID]*Author, len(users))
+	for _, user := range users {
+		if user.UpdatedAt.After(lastModified) {
+			lastModified = user.UpdatedAt
+		}
+		authors[user.ID] = &Author{
+			ID:          user.ID,
+			Handle:      user.Handle,
+			DisplayName: user.DisplayName,
+			AvatarURL:   c.mediaURL(user.AvatarKey),
+		}
+	}
+	for i := range chirps {
+		chirps[i].Author = authors[chirps[i].UserID]
+	}
+	return lastModified, nil
+}
+
+func validateProfile(p *profileParams) map[string]string {
+	fieldErrors := make(map[string]string)
+	check := func(name string, value *string) {
+		if value == nil {
+			return
+		}
+		*value = strings.TrimSpace(*value)
+		if utf8.RuneCountInString(*value) > profileFieldLimits[name] {
+			fieldErrors[name] = "is too long"
+		}
+	}
+	check("display_name", p.DisplayName)
+	check("bio", p.Bio)
+	check("location", p.Location)
+	check("website", p.Website)
+
+	if p.Website != nil && *p.Website != "" && fieldErrors["website"] == "" {
+		u, err := url.Parse(*p.Website)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fieldErrors["website"] = "must be an http or https URL"
+		}
+	}
+	return fieldErrors
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+func (c *apiConfig) uploadAvatar(w http.ResponseWriter, r *http.Request) {
+	c.putProfileImage(w, r, "avatar", media.Avatar)
+}
+
+func (c *apiConfig) uploadBanner(w http.ResponseWriter, r *http.Request) {
+	c.putProfileImage(w, r, "banner", media.Banner)
+}
+
+func (c *apiConfig) deleteAvatar(w http.ResponseWriter, r *http.Request) {
+	c.removeProfileImage(w, r, "avatar")
+}
+
+func (c *apiConfig) deleteBanner(w http.ResponseWriter, r *http.Request) {
+	c.removeProfileImage(w, r, "banner")
+}
+
+// imageUpload returns the uploaded image, sent either as the raw request
+// body or as the "image" field of a multipart form.
+func imageUpload(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (c *apiConfig) setProfileImage(ctx context.Context, kind string, userID uuid.UUID, key sql.NullString) (database.User, error) {
+	if kind == "avatar" {
+		return c.dbQueries.SetAvatar(ctx, database.SetAvatarParams{ID: userID, AvatarKey: key})
+	}
+	return c.dbQueries.SetBanner(ctx, database.SetBannerParams{ID: userID, BannerKey: key})
+}
+
+func profileImageKey(kind string, user database.User) sql.NullString {
+	if kind == "avatar" {
+		return user.AvatarKey
+	}
+	return user.BannerKey
+}
+
+func (c *apiConfig) putProfileImage(w http.ResponseWriter, r *http.Request, kind string, spec media.Spec) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageUploadBytes)
+	upload, err := imageUpload(r)
+	if err == nil {
+		var data []byte
+		data, err = media.Process(upload, spec)
+		if err == nil {
+			c.storeProfileImage(w, r, kind, userID, data)
+			return
+		}
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		respondWithError(w, "Image must be at most 5 MB", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, media.ErrUnsupportedImage):
+		respondWithError(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, media.ErrImageTooLarge):
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+	default:
+		respondWithError(w, "Failed to read image", http.StatusBadRequest)
+	}
+}
+
+func (c *apiConfig) storeProfileImage(w http.ResponseWriter, r *http.Request, kind string, userID uuid.UUID, data []byte) {
+	current, err := c.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+
+	// A fresh key per upload means caches never serve the old image.
+	key := kind + "s/" + userID.String() + "-" + uuid.NewString() + ".jpg"
+	if err := c.media.Put(r.Context(), key, data, "image/jpeg"); err != nil {
+		respondWithError(w, "Failed to store image", http.StatusInternalServerError)
+		log.Printf("Error storing %s: %v", kind, err)
+		return
+	}
+	user, err := c.setProfileImage(r.Context(), kind, userID, sql.NullString{String: key, Valid: true})
+	if err != nil {
+		c.media.Delete(r.Context(), key)
+		respondWithError(w, "Failed to update profile", http.StatusInternalServerError)
+		log.Printf("Error setting %s: %v", kind, err)
+		return
+	}
+	if old := profileImageKey(kind, current); old.Valid {
+		if err := c.media.Delete(r.Context(), old.String); err != nil {
+			log.Printf("Error deleting old %s: %v", kind, err)
+		}
+	}
+
+	JSONUser, err := c.userResponse(user)
+	if err != nil {
+		respondWithError(w, "Failed to create user response", http.StatusInternalServerError)
+		log.Printf("Error creating user response: %v", err)
+		return
+	}
+	respondWithJSON(w, JSONUser, http.StatusOK)
+}
+
+func (c *apiConfig) removeProfileImage(w http.ResponseWriter, r *http.Request, kind string) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	current, err := c.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+	if _, err := c.setProfileImage(r.Context(), kind, userID, sql.NullString{}); err != nil {
+		respondWithError(w, "Failed to update profile", http.StatusInternalServerError)
+		log.Printf("Error removing %s: %v", kind, err)
+		return
+	}
+	if old := profileImageKey(kind, current); old.Valid {
+		if err := c.media.Delete(r.Context(), old.String); err != nil {
+			log.Printf("Error deleting %s: %v", kind, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
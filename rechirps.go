@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+func (c *apiConfig) Rechirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+	chirp, err := c.dbQueries.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+		log.Printf("Error retrieving chirp: %v", err)
+		return
+	}
+
+	blocked, err := c.isBlockedEitherWay(r.Context(), userID, chirp.UserID)
+	if err != nil {
+		respondWithError(w, "Failed to rechirp", http.StatusInternalServerError)
+		log.Printf("Error checking blocks: %v", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, "You cannot rechirp this chirp", http.StatusForbidden)
+		return
+	}
+
+	added, err := c.dbQueries.Rechirp(r.Context(), database.RechirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to rechirp", http.StatusInternalServerError)
+		log.Printf("Error rechirping: %v", err)
+		return
+	}
+	if added > 0 {
+		c.publishNotification(chirp.UserID, notification{Type: "rechirp", FromUserID: userID, ChirpID: chirpID})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *apiConfig) UndoRechirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+
+	err = c.dbQueries.UndoRechirp(r.Context(), database.UndoRechirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to undo rechirp", http.StatusInternalServerError)
+		log.Printf("Error undoing rechirp: %v", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
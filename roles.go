@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+var (
+	errOutranked = errors.New("target's role is not below the caller's")
+	errLastAdmin = errors.New("instance would be left without an admin")
+)
+
+// lockTarget loads and locks the user a moderation or role change is aimed
+// at, and rejects the change unless the caller's role is strictly above
+// theirs. No role outranks admin, so admins can't be suspended at all.
+func lockTarget(ctx context.Context, q *database.Queries, caller database.User, targetID uuid.UUID) (database.User, error) {
+	target, err := q.GetUserByIDForUpdate(ctx, targetID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if !auth.Role(caller.Role).Outranks(auth.Role(target.Role)) {
+		return database.User{}, errOutranked
+	}
+	return target, nil
+}
+
+// SetUserRole changes a user's role. The new role is in their access tokens
+// from their next refresh, but takes effect for permission checks at once.
+// Admins can change the roles of users below them, and can step down
+// themselves as long as another admin remains.
+func (c *apiConfig) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	caller, err := c.getLoggedInAccount(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var p roleParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, "Failed to decode role", http.StatusBadRequest)
+		return
+	}
+	role, err := auth.ParseRole(p.Role)
+	if err != nil {
+		respondWithFieldErrors(w, "Invalid role", map[string]string{"role": "must be user, moderator or admin"})
+		return
+	}
+	if role.Outranks(auth.Role(caller.Role)) {
+		respondWithError(w, "You cannot grant a role above your own", http.StatusForbidden)
+		return
+	}
+
+	tx, err := c.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, "Failed to set role", http.StatusInternalServerError)
+		log.Printf("Error starting transaction: %v", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
+
+	// Role changes are rare, so each one locks the admins up front. Two
+	// admins stepping down at once then can't both see the other remaining.
+	admins, err := qtx.LockAdmins(r.Context())
+	if err != nil {
+		respondWithError(w, "Failed to set role", http.StatusInternalServerError)
+		log.Printf("Error locking admins: %v", err)
+		return
+	}
+	var target database.User
+	if userID == caller.ID {
+		target, err = qtx.GetUserByIDForUpdate(r.Context(), userID)
+	} else {
+		target, err = lockTarget(r.Context(), qtx, caller, userID)
+	}
+	if err == nil && target.Role == string(auth.RoleAdmin) && role != auth.RoleAdmin && len(admins) <= 1 {
+		err = errLastAdmin
+	}
+	if err == nil {
+		err = recordAudit(r.Context(), qtx, database.CreateAuditEntryParams{
+			ActorID:   actor(caller.ID),
+			SubjectID: userID,
+			Action:    auditSetRole,
+		}, auditRoleChange{From: target.Role, To: string(role)})
+	}
+	if err == nil {
+		target, err = qtx.SetUserRole(r.Context(), database.SetUserRoleParams{ID: userID, Role: string(role)})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	switch {
+	case err == nil:
+		c.respondWithUser(w, target)
+	case err == sql.ErrNoRows:
+		respondWithError(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, errOutranked):
+		respondWithError(w, "You cannot change the role of a user ranked at or above you", http.StatusForbidden)
+	case errors.Is(err, errLastAdmin):
+		respondWithError(w, "Cannot demote the last admin", http.StatusConflict)
+	default:
+		respondWithError(w, "Failed to set role", http.StatusInternalServerError)
+		log.Printf("Error setting role: %v", err)
+	}
+}
+
+// runCommand runs a maintenance command given on the command line instead
+// of starting the server.
+func runCommand(ctx context.Context, q *database.Queries, args []string) error {
+	switch args[0] {
+	case "grant-admin":
+		if len(args) != 2 {
+			return errors.New("usage: chirpy grant-admin <email or @handle>")
+		}
+		return grantAdmin(ctx, q, args[1])
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// grantAdmin makes a user an admin. It is how the first admin is created,
+// since only admins can change roles through the API.
+func grantAdmin(ctx context.Context, q *database.Queries, who string) error {
+	var user database.User
+	var err error
+	if handle, ok := strings.CutPrefix(who, "@"); ok {
+		user, err = q.GetUserByHandle(ctx, handle)
+	} else {
+		user, err = q.GetUserByEmail(ctx, who)
+	}
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no user %s", who)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := q.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: string(auth.RoleAdmin)}); err != nil {
+		return err
+	}
+	err = recordAudit(ctx, q, database.CreateAuditEntryParams{
+		SubjectID: user.ID,
+		Action:    auditSetRole,
+	}, auditRoleChange{From: user.Role, To: string(auth.RoleAdmin)})
+	if err != nil {
+		return err
+	}
+	log.Printf("@%s is now an admin; their next access token will carry the role", user.Handle)
+	return nil
+}
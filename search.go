@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/pagination"
+)
+
+const maxSearchQueryLength = 100
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchUsers lists users matching ?query= by handle or display name, most
+// followed first. Without a query it is a directory of everyone.
+func (c *apiConfig) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := c.getViewer(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	query := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("query")), "@"))
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		respondWithError(w, "Query is too long", http.StatusBadRequest)
+		return
+	}
+	cursor, err := pagination.DecodeRank(r.URL.Query().Get("cursor"))
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := pagination.ParseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := database.SearchUsersParams{
+		Query:    query,
+		Prefix:   likeEscaper.Replace(query) + "%",
+		ViewerID: viewerID,
+		Limit:    limit,
+	}
+	if cursor != nil {
+		params.BeforeCount = sql.NullInt32{Int32: int32(cursor.Rank), Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+	users, err := c.dbQueries.SearchUsers(r.Context(), params)
+	if err != nil {
+		respondWithError(w, "Failed to search users", http.StatusInternalServerError)
+		log.Printf("Error searching users: %v", err)
+		return
+	}
+
+	response := directoryResponse{Users: []directoryEntry{}}
+	for _, user := range users {
+		response.Users = append(response.Users, directoryEntry{
+			ID:             user.ID,
+			Handle:         user.Handle,
+			DisplayName:    user.DisplayName,
+			Bio:            user.Bio,
+			AvatarURL:      c.mediaURL(user.AvatarKey),
+			IsChirpyRed:    user.IsChirpyRed,
+			FollowersCount: int64(user.FollowerCount),
+		})
+	}
+	if len(users) == int(limit) {
+		last := users[len(users)-1]
+		response.NextCursor = pagination.RankCursor{Rank: int64(last.FollowerCount), ID: last.ID}.Encode()
+	}
+	respondWithJSON(w, response, http.StatusOK)
+}
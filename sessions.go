@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+const maxUserAgentLength = 256
+
+var errSessionEnded = errors.New("session has ended")
+
+// clientIP is the address the request came from. X-Forwarded-For is
+// ignored because any client can set it.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func truncate(s string, maxRunes int) string {
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	return string([]rune(s)[:maxRunes])
+}
+
+// checkSession rejects access tokens whose session has been logged out or
+// revoked, so ending a session takes effect straight away.
+func (c *apiConfig) checkSession(ctx context.Context, claims auth.Claims) error {
+	if claims.SessionID == uuid.Nil {
+		return nil
+	}
+	active, err := c.dbQueries.IsSessionActive(ctx, database.IsSessionActiveParams{
+		FamilyID: claims.SessionID,
+		UserID:   claims.UserID,
+	})
+	if err != nil {
+		return err
+	}
+	if !active {
+		return errSessionEnded
+	}
+	return nil
+}
+
+// stillAuthorized re-runs checkClaims for a long-lived connection, which
+// otherwise would only be checked when it opened. It reports false once the
+// session has ended or the account has been suspended, closed or deleted.
+// Database errors are logged and don't end the connection.
+func (c *apiConfig) stillAuthorized(ctx context.Context, claims auth.Claims) bool {
+	_, err := c.checkClaims(ctx, claims)
+	switch {
+	case err == nil:
+		return true
+	case err == sql.ErrNoRows, errors.Is(err, errSessionEnded),
+		errors.Is(err, errAccountSuspended), errors.Is(err, errAccountClosing):
+		return false
+	}
+	log.Printf("Error rechecking session: %v", err)
+	return true
+}
+
+func (c *apiConfig) ListSessions(w http.ResponseWriter, r *http.Request) {
+	claims, err := c.getLoggedInClaims(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rows, err := c.dbQueries.GetActiveSessions(r.Context(), claims.UserID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+		log.Printf("Error retrieving sessions: %v", err)
+		return
+	}
+	sessions := []session{}
+	for _, row := range rows {
+		sessions = append(sessions, session{
+			ID:         row.FamilyID,
+			UserAgent:  row.UserAgent,
+			IPAddress:  row.IpAddress,
+			SignedInAt: row.SignedInAt,
+			LastUsedAt: row.LastUsedAt,
+			ExpiresAt:  row.ExpiresAt,
+			Current:    row.FamilyID == claims.SessionID,
+		})
+	}
+	respondWithJSON(w, sessions, http.StatusOK)
+}
+
+// RevokeSession logs out one of the user's sessions, which may be the
+// current one.
+func (c *apiConfig) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	sessionID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+	revoked, err := c.dbQueries.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to revoke session", http.StatusInternalServerError)
+		log.Printf("Error revoking session: %v", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions logs out every session except the one making the
+// request.
+func (c *apiConfig) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	claims, err := c.getLoggedInClaims(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	err = c.dbQueries.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+		UserID:   claims.UserID,
+		FamilyID: claims.SessionID,
+	})
+	if err != nil {
+		respondWithError(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		log.Printf("Error revoking sessions: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,30 @@
+-- name: GetActorKeys :one
+SELECT * FROM actor_keys WHERE user_id = $1;
+
+-- name: CreateActorKeys :one
+INSERT INTO actor_keys (user_id, public_key_pem, private_key_pem)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET user_id = actor_keys.user_id
+RETURNING *;
+
+-- name: AddRemoteFollower :exec
+INSERT INTO remote_followers (user_id, actor_id, inbox)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, actor_id) DO UPDATE SET inbox = EXCLUDED.inbox;
+
+-- name: RemoveRemoteFollower :exec
+DELETE FROM remote_followers WHERE user_id = $1 AND actor_id = $2;
+
+-- name: GetRemoteFollowerInboxes :many
+SELECT DISTINCT inbox FROM remote_followers WHERE user_id = $1;
+
+-- name: CountRemoteFollowers :one
+SELECT COUNT(*) FROM remote_followers WHERE user_id = $1;
+
+-- name: CreateRemotePost :exec
+INSERT INTO remote_posts (ap_id, actor_id, content, in_reply_to, published_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (ap_id) DO NOTHING;
+
+-- name: DeleteRemotePost :exec
+DELETE FROM remote_posts WHERE ap_id = $1 AND actor_id = $2;
@@ -0,0 +1,41 @@
+-- name: AddChirpViews :exec
+-- Rows for chirps deleted since the views were counted are dropped rather
+-- than failing the whole batch.
+INSERT INTO chirp_views (chirp_id, day, views)
+SELECT v.chirp_id, v.day, v.views
+FROM unnest(@chirp_ids::uuid[], @days::date[], @views::bigint[]) AS v(chirp_id, day, views)
+WHERE EXISTS (SELECT 1 FROM chirps WHERE chirps.id = v.chirp_id)
+ON CONFLICT (chirp_id, day) DO UPDATE SET views = chirp_views.views + EXCLUDED.views;
+
+-- name: GetAuthorChirpStats :many
+SELECT c.id, c.created_at,
+    COALESCE((SELECT SUM(v.views) FROM chirp_views v WHERE v.chirp_id = c.id), 0)::bigint AS views,
+    (SELECT COUNT(*) FROM likes l WHERE l.chirp_id = c.id) AS likes,
+    (SELECT COUNT(*) FROM chirps r WHERE r.reply_to_id = c.id) AS replies
+FROM chirps c
+WHERE c.user_id = sqlc.arg('user_id')
+  AND (c.expires_at IS NULL OR c.expires_at > NOW())
+  AND (sqlc.narg('before_time')::timestamp IS NULL
+       OR (c.created_at, c.id) < (sqlc.narg('before_time')::timestamp, sqlc.narg('before_id')::uuid))
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetAuthorDailyStats :many
+SELECT d.day::date AS day,
+    COALESCE((
+        SELECT SUM(v.views) FROM chirp_views v
+        JOIN chirps c ON c.id = v.chirp_id
+        WHERE c.user_id = sqlc.arg('user_id') AND v.day = d.day::date
+    ), 0)::bigint AS views,
+    (
+        SELECT COUNT(*) FROM likes l
+        JOIN chirps c ON c.id = l.chirp_id
+        WHERE c.user_id = sqlc.arg('user_id') AND l.created_at::date = d.day::date
+    ) AS likes,
+    (
+        SELECT COUNT(*) FROM chirps r
+        JOIN chirps c ON c.id = r.reply_to_id
+        WHERE c.user_id = sqlc.arg('user_id') AND r.created_at::date = d.day::date
+    ) AS replies
+FROM generate_series(sqlc.arg('since')::date, CURRENT_DATE, INTERVAL '1 day') AS d(day)
+ORDER BY d.day;
@@ -0,0 +1,9 @@
+-- name: CreateAuditEntry :exec
+INSERT INTO audit_log (id, actor_id, subject_id, action, chirp_id, details)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5);
+
+-- name: GetUserAuditEntries :many
+-- Entries about the user and entries for actions they took.
+SELECT * FROM audit_log
+WHERE subject_id = $1 OR actor_id = $1
+ORDER BY created_at, id;
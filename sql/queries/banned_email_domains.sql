@@ -0,0 +1,18 @@
+-- name: BanEmailDomain :one
+INSERT INTO banned_email_domains (domain, reason)
+VALUES ($1, $2)
+ON CONFLICT (domain) DO UPDATE SET reason = EXCLUDED.reason
+RETURNING *;
+
+-- name: UnbanEmailDomain :execrows
+DELETE FROM banned_email_domains WHERE domain = $1;
+
+-- name: GetBannedEmailDomains :many
+SELECT * FROM banned_email_domains ORDER BY domain;
+
+-- name: IsEmailDomainBanned :one
+-- Matches the domain itself and any of its parent domains.
+SELECT EXISTS (
+    SELECT 1 FROM banned_email_domains
+    WHERE domain = $1 OR RIGHT($1, LENGTH(domain) + 1) = '.' || domain
+);
@@ -0,0 +1,42 @@
+-- name: BlockUser :exec
+INSERT INTO blocks (blocker_id, blocked_id)
+VALUES ($1, $2)
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetBlocks :many
+SELECT * FROM blocks WHERE blocker_id = $1 ORDER BY created_at DESC;
+
+-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2)
+       OR (blocker_id = $2 AND blocked_id = $1)
+);
+
+-- name: RemoveFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = $1 AND followee_id = $2)
+   OR (follower_id = $2 AND followee_id = $1);
+
+-- name: MuteUser :exec
+INSERT INTO mutes (muter_id, muted_id)
+VALUES ($1, $2)
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: UnmuteUser :exec
+DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetMutes :many
+SELECT * FROM mutes WHERE muter_id = $1 ORDER BY created_at DESC;
+
+-- name: GetHiddenUserIDs :many
+-- Users whose chirps the viewer must not see: anyone blocked in either
+-- direction plus anyone the viewer has muted.
+SELECT blocked_id AS user_id FROM blocks WHERE blocks.blocker_id = $1
+UNION
+SELECT blocker_id FROM blocks WHERE blocks.blocked_id = $1
+UNION
+SELECT muted_id FROM mutes WHERE mutes.muter_id = $1;
@@ -1,28 +1,49 @@
 -- name: CreateChirp :one
-INSERT into chirps (id, user_id, body) 
-values (gen_random_uuid(), $1, $2)
+INSERT into chirps (id, user_id, body, reply_to_id, thread_id, content_warning, sensitive, expires_at)
+values (
+    gen_random_uuid(), $1, $2, sqlc.narg('reply_to_id'),
+    (SELECT COALESCE(parent.thread_id, parent.id) FROM chirps parent WHERE parent.id = sqlc.narg('reply_to_id')),
+    sqlc.narg('content_warning'), sqlc.arg('sensitive'), NOW() + sqlc.narg('expires_in')::integer * INTERVAL '1 second'
+)
 RETURNING *;
 
--- name: GetChirps :many
-SELECT * from chirps
-ORDER BY created_at ASC;
+-- name: GetChirpByID :one
+SELECT * from chirps where id = $1 AND (expires_at IS NULL OR expires_at > NOW());
 
--- name: GetChirpsDesc :many
-SELECT * from chirps
-ORDER BY created_at DESC;
+-- name: GetChirpByIDForUpdate :one
+SELECT * from chirps where id = $1 AND (expires_at IS NULL OR expires_at > NOW()) FOR UPDATE;
 
--- name: GetChirpsByUser :many
-SELECT * from chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
+-- name: DeleteChirp :exec
+DELETE FROM chirps WHERE id = $1;
 
--- name: GetChirpsByUserDesc :many
-SELECT * from chirps
-WHERE user_id = $1
-ORDER BY created_at DESC;
+-- name: DeleteExpiredChirps :many
+-- SKIP LOCKED lets several instances sweep at once without deleting the
+-- same rows or waiting on each other.
+DELETE FROM chirps
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE expires_at <= NOW()
+    ORDER BY expires_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
 
--- name: GetChirpByID :one
-SELECT * from chirps where id = $1;
+-- name: SetChirpContentWarning :one
+UPDATE chirps SET content_warning = $2, sensitive = $3, updated_at = NOW()
+WHERE id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+RETURNING *;
 
--- name: DeleteChirp :exec
-DELETE FROM chirps WHERE id = $1;
\ No newline at end of file
+-- name: DeleteUserChirps :many
+DELETE FROM chirps WHERE user_id = $1
+RETURNING *;
+
+-- name: CountUserChirps :one
+-- Counts the chirps ListChirps would show for the user.
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+    AND (expires_at IS NULL OR expires_at > NOW())
+    AND user_id NOT IN (SELECT id FROM users WHERE suspended_until > NOW());
+
+-- name: GetUserChirps :many
+SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at, id;
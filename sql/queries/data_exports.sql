@@ -0,0 +1,29 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, user_id, expires_at)
+VALUES (gen_random_uuid(), $1, $2)
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports WHERE id = $1 AND user_id = $2;
+
+-- name: GetPendingDataExport :one
+-- An export still pending after an hour has stalled; a new one can be
+-- started.
+SELECT * FROM data_exports
+WHERE user_id = $1 AND status = 'pending' AND created_at > NOW() - INTERVAL '1 hour'
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: FailPendingDataExports :execrows
+-- Builds run in the server process, so at startup any still pending were
+-- lost with the previous one.
+UPDATE data_exports SET status = 'failed', completed_at = NOW() WHERE status = 'pending';
+
+-- name: FinishDataExport :exec
+UPDATE data_exports SET status = $2, completed_at = NOW() WHERE id = $1;
+
+-- name: DeleteExpiredDataExports :many
+DELETE FROM data_exports WHERE expires_at <= NOW() RETURNING id;
+
+-- name: DeleteUserDataExports :many
+DELETE FROM data_exports WHERE user_id = $1 RETURNING id;
@@ -0,0 +1,66 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id)
+VALUES ($1, $2)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: CountFollowers :one
+SELECT COUNT(*) FROM follows WHERE followee_id = $1;
+
+-- name: CountFollowing :one
+SELECT COUNT(*) FROM follows WHERE follower_id = $1;
+
+-- name: GetFollowers :many
+SELECT follower_id AS user_id, created_at FROM follows
+WHERE followee_id = sqlc.arg('user_id')
+  AND (sqlc.narg('before_time')::timestamp IS NULL
+       OR (created_at, follower_id) < (sqlc.narg('before_time')::timestamp, sqlc.narg('before_id')::uuid))
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetFollowing :many
+SELECT followee_id AS user_id, created_at FROM follows
+WHERE follower_id = sqlc.arg('user_id')
+  AND (sqlc.narg('before_time')::timestamp IS NULL
+       OR (created_at, followee_id) < (sqlc.narg('before_time')::timestamp, sqlc.narg('before_id')::uuid))
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetHomeTimeline :many
+-- Each followed author (and the user themself) contributes at most LIMIT
+-- chirp IDs via an index probe, so the cost is bounded by the follow count
+-- rather than the size of the chirps table.
+SELECT chirps.* FROM chirps
+WHERE chirps.id IN (
+    SELECT recent.id
+    FROM (
+        SELECT followee_id AS author_id FROM follows
+        WHERE follower_id = sqlc.arg('user_id')
+          AND followee_id NOT IN (SELECT muted_id FROM mutes WHERE muter_id = sqlc.arg('user_id'))
+          AND followee_id NOT IN (SELECT id FROM users WHERE suspended_until > NOW())
+        UNION ALL
+        SELECT sqlc.arg('user_id')::uuid
+    ) authors
+    CROSS JOIN LATERAL (
+        SELECT id FROM chirps
+        WHERE chirps.user_id = authors.author_id
+          AND (chirps.expires_at IS NULL OR chirps.expires_at > NOW())
+          AND (sqlc.narg('before_time')::timestamp IS NULL
+               OR (chirps.created_at, chirps.id) < (sqlc.narg('before_time')::timestamp, sqlc.narg('before_id')::uuid))
+          AND (NOT sqlc.arg('hide_sensitive')::boolean
+               OR chirps.user_id = sqlc.arg('user_id')
+               OR (NOT chirps.sensitive AND chirps.content_warning IS NULL))
+        ORDER BY chirps.created_at DESC, chirps.id DESC
+        LIMIT sqlc.arg('limit')
+    ) recent
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetUserFollows :many
+-- Follows in both directions.
+SELECT * FROM follows
+WHERE follower_id = $1 OR followee_id = $1
+ORDER BY created_at;
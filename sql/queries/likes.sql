@@ -0,0 +1,10 @@
+-- name: LikeChirp :execrows
+INSERT INTO likes (user_id, chirp_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnlikeChirp :exec
+DELETE FROM likes WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetUserLikes :many
+SELECT * FROM likes WHERE user_id = $1 ORDER BY created_at;
@@ -0,0 +1,10 @@
+-- name: Rechirp :execrows
+INSERT INTO rechirps (user_id, chirp_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UndoRechirp :exec
+DELETE FROM rechirps WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetUserRechirps :many
+SELECT * FROM rechirps WHERE user_id = $1 ORDER BY created_at;
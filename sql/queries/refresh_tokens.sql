@@ -1,15 +1,51 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, user_id, expires_at)
-VALUES ($1, $2, $3)
+INSERT INTO refresh_tokens (token, user_id, expires_at, family_id, user_agent, ip_address)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: GetRefreshToken :one
 SELECT * FROM refresh_tokens WHERE token = $1;
 
+-- name: RotateRefreshToken :one
+-- Revokes a live token so it can be replaced. Of several concurrent
+-- rotations of the same token only the first gets a row back; the row lock
+-- makes the others see it already revoked.
+UPDATE refresh_tokens SET revoked_at = NOW(), last_used_at = NOW(), updated_at = NOW(), replaced_by = $2
+WHERE token = $1 AND revoked_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE token = $1;
 
--- name: GetUserByRefreshToken :one
-SELECT users.* FROM users
-JOIN refresh_tokens ON refresh_tokens.user_id = users.id
-WHERE refresh_tokens.token = $1;
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeAllRefreshTokens :exec
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: GetUserRefreshTokens :many
+SELECT * FROM refresh_tokens WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetActiveSessions :many
+-- One row per session: rotation leaves each family a single live token.
+SELECT family_id, user_agent, ip_address, last_used_at, expires_at,
+    (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = refresh_tokens.family_id)::timestamp AS signed_in_at
+FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY last_used_at DESC;
+
+-- name: IsSessionActive :one
+SELECT EXISTS (
+    SELECT 1 FROM refresh_tokens
+    WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+);
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :exec
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL;
@@ -1,19 +1,110 @@
 -- name: CreateUser :one
-INSERT INTO users (id, email, hashed_password)
-VALUES (gen_random_uuid(), $1, $2)
+INSERT INTO users (id, email, hashed_password, handle)
+VALUES (gen_random_uuid(), $1, $2, $3)
 RETURNING *;
 
 -- name: DeleteUsers :exec
 DELETE FROM users;
 
 -- name: GetUserByEmail :one
-SELECT * FROM users WHERE email = $1;
+SELECT * FROM users WHERE LOWER(email) = LOWER($1);
+
+-- name: GetUserByHandle :one
+SELECT * FROM users WHERE LOWER(handle) = LOWER($1);
+
+-- name: GetUsersByIDs :many
+SELECT * FROM users WHERE id = ANY(@ids::uuid[]);
 
 -- name: GetUserByID :one
 SELECT * FROM users WHERE id = $1;
 
--- name: UpdateUser :one
-UPDATE users SET email = $1, hashed_password = $2, updated_at = NOW() WHERE id = $3 RETURNING *;
+-- name: GetUserByIDForUpdate :one
+SELECT * FROM users WHERE id = $1 FOR UPDATE;
+
+-- name: SetPendingEmail :exec
+UPDATE users SET pending_email = $2, updated_at = NOW() WHERE id = $1;
+
+-- name: UpdatePassword :exec
+UPDATE users SET hashed_password = $2, updated_at = NOW() WHERE id = $1;
+
+-- name: UpdateProfile :one
+UPDATE users SET
+    display_name = COALESCE(sqlc.narg('display_name'), display_name),
+    bio = COALESCE(sqlc.narg('bio'), bio),
+    location = COALESCE(sqlc.narg('location'), location),
+    website = COALESCE(sqlc.narg('website'), website),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: SetAvatar :one
+UPDATE users SET avatar_key = $2, updated_at = NOW() WHERE id = $1 RETURNING *;
+
+-- name: SetBanner :one
+UPDATE users SET banner_key = $2, updated_at = NOW() WHERE id = $1 RETURNING *;
+
+-- name: UpdateSensitiveContent :one
+UPDATE users SET sensitive_content = $2, updated_at = NOW() WHERE id = $1 RETURNING *;
 
 -- name: GiveChirpyRed :one
 UPDATE users SET is_chirpy_red = true, updated_at = NOW() WHERE id = $1 RETURNING *;
+
+-- name: VerifyEmail :one
+-- Verifies the current address, or swaps in the pending one it matches.
+UPDATE users SET
+    email = $2,
+    pending_email = CASE WHEN pending_email = $2 THEN NULL ELSE pending_email END,
+    email_verified_at = CASE WHEN email = $2 THEN COALESCE(email_verified_at, NOW()) ELSE NOW() END,
+    updated_at = NOW()
+WHERE id = $1 AND (email = $2 OR pending_email = $2)
+RETURNING *;
+
+-- name: ScheduleUserDeletion :one
+UPDATE users SET deletion_scheduled_at = $2, updated_at = NOW() WHERE id = $1 RETURNING *;
+
+-- name: CancelUserDeletion :exec
+UPDATE users SET deletion_scheduled_at = NULL, updated_at = NOW() WHERE id = $1;
+
+-- name: GetUsersDueForDeletion :many
+SELECT * FROM users
+WHERE deletion_scheduled_at <= NOW()
+ORDER BY deletion_scheduled_at
+LIMIT $1;
+
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1;
+
+-- name: SearchUsers :many
+-- Matches handles and display names that start with the query or are
+-- trigram-similar to it; an empty query lists everyone. Users blocked
+-- either way by the viewer, suspended users and accounts being deleted are
+-- left out.
+SELECT * FROM users
+WHERE deletion_scheduled_at IS NULL
+  AND (suspended_until IS NULL OR suspended_until <= NOW())
+  AND (sqlc.arg('query')::text = ''
+       OR LOWER(handle) LIKE sqlc.arg('prefix')::text
+       OR LOWER(display_name) LIKE sqlc.arg('prefix')::text
+       OR LOWER(handle) % sqlc.arg('query')::text
+       OR LOWER(display_name) % sqlc.arg('query')::text)
+  AND NOT EXISTS (
+      SELECT 1 FROM blocks
+      WHERE (blocker_id = sqlc.arg('viewer_id') AND blocked_id = users.id)
+         OR (blocker_id = users.id AND blocked_id = sqlc.arg('viewer_id'))
+  )
+  AND (sqlc.narg('before_count')::integer IS NULL
+       OR (follower_count, id) < (sqlc.narg('before_count')::integer, sqlc.narg('before_id')::uuid))
+ORDER BY follower_count DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: SuspendUser :one
+UPDATE users SET suspended_until = $2, suspension_reason = $3, updated_at = NOW() WHERE id = $1 RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users SET suspended_until = NULL, suspension_reason = NULL, updated_at = NOW() WHERE id = $1 RETURNING *;
+
+-- name: SetUserRole :one
+UPDATE users SET role = $2, updated_at = NOW() WHERE id = $1 RETURNING *;
+
+-- name: LockAdmins :many
+SELECT id FROM users WHERE role = 'admin' AND deletion_scheduled_at IS NULL FOR UPDATE;
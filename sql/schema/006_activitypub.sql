@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE actor_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    public_key_pem TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE remote_followers (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id TEXT NOT NULL,
+    inbox TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, actor_id)
+);
+
+CREATE TABLE remote_posts (
+    ap_id TEXT PRIMARY KEY,
+    actor_id TEXT NOT NULL,
+    content TEXT NOT NULL,
+    in_reply_to TEXT,
+    published_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX remote_posts_actor_id_idx ON remote_posts (actor_id);
+
+-- +goose Down
+DROP TABLE remote_posts;
+DROP TABLE remote_followers;
+DROP TABLE actor_keys;
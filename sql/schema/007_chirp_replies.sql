@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    ADD COLUMN thread_id UUID REFERENCES chirps(id) ON DELETE CASCADE;
+
+CREATE INDEX chirps_thread_id_idx ON chirps (thread_id);
+
+-- +goose Down
+DROP INDEX chirps_thread_id_idx;
+ALTER TABLE chirps DROP COLUMN thread_id, DROP COLUMN reply_to_id;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE likes (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX likes_chirp_id_idx ON likes (chirp_id);
+
+-- Views are counted in memory and flushed periodically, so one row holds
+-- a chirp's total for a day rather than one row per impression.
+CREATE TABLE chirp_views (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    views BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (chirp_id, day)
+);
+
+CREATE INDEX chirps_reply_to_id_idx ON chirps (reply_to_id);
+
+-- +goose Down
+DROP INDEX chirps_reply_to_id_idx;
+DROP TABLE chirp_views;
+DROP TABLE likes;
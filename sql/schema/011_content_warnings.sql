@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN content_warning TEXT,
+    ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT false;
+
+-- How a user wants chirps with a content warning or the sensitive flag
+-- shown: expanded, collapsed behind the warning, or left out of listings.
+ALTER TABLE users
+    ADD COLUMN sensitive_content TEXT NOT NULL DEFAULT 'warn'
+    CHECK (sensitive_content IN ('expand', 'warn', 'hide'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN sensitive_content;
+ALTER TABLE chirps DROP COLUMN sensitive, DROP COLUMN content_warning;
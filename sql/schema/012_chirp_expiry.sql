@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN expires_at TIMESTAMP;
+
+CREATE INDEX chirps_expires_at_idx ON chirps (expires_at) WHERE expires_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_expires_at_idx;
+ALTER TABLE chirps DROP COLUMN expires_at;
@@ -0,0 +1,92 @@
+-- +goose Up
+CREATE TABLE rechirps (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX rechirps_chirp_id_idx ON rechirps (chirp_id);
+
+-- Engagement counters are kept up to date by triggers so the ranking
+-- scores can be stored columns. top_score weighs rechirps above replies
+-- above likes. hot_score adds a time term to the log of the same number,
+-- so each tenfold increase in engagement is worth 12.5 hours of recency
+-- and the score never needs recomputing as chirps age.
+ALTER TABLE chirps
+    ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN rechirp_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE chirps SET
+    like_count = (SELECT COUNT(*) FROM likes WHERE likes.chirp_id = chirps.id),
+    reply_count = (SELECT COUNT(*) FROM chirps r WHERE r.reply_to_id = chirps.id);
+
+ALTER TABLE chirps
+    ADD COLUMN top_score INTEGER GENERATED ALWAYS AS (
+        like_count + 2 * reply_count + 3 * rechirp_count
+    ) STORED,
+    ADD COLUMN hot_score DOUBLE PRECISION GENERATED ALWAYS AS (
+        LOG(GREATEST(like_count + 2 * reply_count + 3 * rechirp_count, 1))
+        + EXTRACT(EPOCH FROM created_at - TIMESTAMP '2020-01-01') / 45000
+    ) STORED;
+
+CREATE INDEX chirps_top_score_idx ON chirps (top_score DESC, id DESC);
+CREATE INDEX chirps_hot_score_idx ON chirps (hot_score DESC, id DESC);
+CREATE INDEX chirps_user_id_top_score_idx ON chirps (user_id, top_score DESC, id DESC);
+CREATE INDEX chirps_user_id_hot_score_idx ON chirps (user_id, hot_score DESC, id DESC);
+
+-- +goose StatementBegin
+CREATE FUNCTION count_chirp_engagement() RETURNS trigger AS $$
+DECLARE
+    delta INTEGER := CASE WHEN TG_OP = 'INSERT' THEN 1 ELSE -1 END;
+    row_chirp UUID;
+BEGIN
+    IF TG_TABLE_NAME = 'chirps' THEN
+        IF TG_OP = 'INSERT' THEN
+            row_chirp := NEW.reply_to_id;
+        ELSE
+            row_chirp := OLD.reply_to_id;
+        END IF;
+        UPDATE chirps SET reply_count = reply_count + delta WHERE id = row_chirp;
+    ELSE
+        IF TG_OP = 'INSERT' THEN
+            row_chirp := NEW.chirp_id;
+        ELSE
+            row_chirp := OLD.chirp_id;
+        END IF;
+        IF TG_TABLE_NAME = 'likes' THEN
+            UPDATE chirps SET like_count = like_count + delta WHERE id = row_chirp;
+        ELSE
+            UPDATE chirps SET rechirp_count = rechirp_count + delta WHERE id = row_chirp;
+        END IF;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER likes_count AFTER INSERT OR DELETE ON likes
+    FOR EACH ROW EXECUTE FUNCTION count_chirp_engagement();
+CREATE TRIGGER rechirps_count AFTER INSERT OR DELETE ON rechirps
+    FOR EACH ROW EXECUTE FUNCTION count_chirp_engagement();
+CREATE TRIGGER replies_count_insert AFTER INSERT ON chirps
+    FOR EACH ROW WHEN (NEW.reply_to_id IS NOT NULL)
+    EXECUTE FUNCTION count_chirp_engagement();
+CREATE TRIGGER replies_count_delete AFTER DELETE ON chirps
+    FOR EACH ROW WHEN (OLD.reply_to_id IS NOT NULL)
+    EXECUTE FUNCTION count_chirp_engagement();
+
+-- +goose Down
+DROP TRIGGER replies_count_delete ON chirps;
+DROP TRIGGER replies_count_insert ON chirps;
+DROP TRIGGER rechirps_count ON rechirps;
+DROP TRIGGER likes_count ON likes;
+DROP FUNCTION count_chirp_engagement();
+ALTER TABLE chirps
+    DROP COLUMN hot_score,
+    DROP COLUMN top_score,
+    DROP COLUMN rechirp_count,
+    DROP COLUMN reply_count,
+    DROP COLUMN like_count;
+DROP TABLE rechirps;
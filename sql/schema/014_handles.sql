@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN handle TEXT;
+
+-- Existing accounts get a placeholder handle derived from their ID.
+UPDATE users SET handle = 'user_' || LEFT(REPLACE(id::text, '-', ''), 12);
+
+ALTER TABLE users ALTER COLUMN handle SET NOT NULL;
+
+CREATE UNIQUE INDEX users_handle_lower_idx ON users (LOWER(handle));
+
+-- +goose Down
+DROP INDEX users_handle_lower_idx;
+ALTER TABLE users DROP COLUMN handle;
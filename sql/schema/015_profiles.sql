@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+    ADD COLUMN location TEXT NOT NULL DEFAULT '',
+    ADD COLUMN website TEXT NOT NULL DEFAULT '',
+    ADD COLUMN avatar_key TEXT,
+    ADD COLUMN banner_key TEXT;
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN banner_key,
+    DROP COLUMN avatar_key,
+    DROP COLUMN website,
+    DROP COLUMN location,
+    DROP COLUMN bio,
+    DROP COLUMN display_name;
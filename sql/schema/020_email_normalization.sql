@@ -0,0 +1,40 @@
+-- +goose Up
+-- Addresses that differ only by case reach the same mailbox, but signup
+-- used to compare them case-sensitively, so an instance may have several
+-- accounts for one address. For each address keep the account verified
+-- most recently, or the oldest if none is verified. The others keep their
+-- chirps and follows: their address moves to pending_email to await
+-- reverification, and email becomes a placeholder that can't receive mail.
+-- They sign in with the placeholder until an operator sorts them out.
+WITH ranked AS (
+    SELECT id, ROW_NUMBER() OVER (
+        PARTITION BY LOWER(email)
+        ORDER BY email_verified_at DESC NULLS LAST, created_at, id
+    ) AS rn
+    FROM users
+)
+UPDATE users
+SET pending_email = users.email,
+    email = users.id::text || '@duplicate.invalid',
+    email_verified_at = NULL,
+    updated_at = NOW()
+FROM ranked
+WHERE users.id = ranked.id AND ranked.rn > 1;
+
+-- Domains are case-insensitive; store them lowercased like new signups.
+UPDATE users
+SET email = LEFT(email, -POSITION('@' IN REVERSE(email)))
+    || LOWER(RIGHT(email, POSITION('@' IN REVERSE(email))))
+WHERE POSITION('@' IN email) > 0;
+
+CREATE UNIQUE INDEX users_email_lower_idx ON users (LOWER(email));
+
+-- +goose Down
+-- Placeholder addresses are swapped back for the originals, but those
+-- accounts stay unverified, and domains stay lowercased because their
+-- original case isn't kept; they still reach the same mailboxes.
+DROP INDEX users_email_lower_idx;
+
+UPDATE users
+SET email = pending_email, pending_email = NULL, updated_at = NOW()
+WHERE email = id::text || '@duplicate.invalid' AND pending_email IS NOT NULL;
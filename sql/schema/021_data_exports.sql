@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE data_exports (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX data_exports_user_id_idx ON data_exports (user_id, created_at DESC);
+CREATE INDEX data_exports_expires_at_idx ON data_exports (expires_at);
+
+-- +goose Down
+DROP TABLE data_exports;
@@ -0,0 +1,41 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- The directory ranks users by followers, so the count is a stored column
+-- kept up to date by a trigger like the chirp engagement counters.
+ALTER TABLE users ADD COLUMN follower_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE users SET
+    follower_count = (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id);
+
+-- +goose StatementBegin
+CREATE FUNCTION count_followers() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET follower_count = follower_count + 1 WHERE id = NEW.followee_id;
+    ELSE
+        UPDATE users SET follower_count = follower_count - 1 WHERE id = OLD.followee_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER follows_count AFTER INSERT OR DELETE ON follows
+    FOR EACH ROW EXECUTE FUNCTION count_followers();
+
+CREATE INDEX users_follower_count_idx ON users (follower_count DESC, id DESC);
+CREATE INDEX users_handle_prefix_idx ON users (LOWER(handle) text_pattern_ops);
+CREATE INDEX users_display_name_prefix_idx ON users (LOWER(display_name) text_pattern_ops);
+CREATE INDEX users_handle_trgm_idx ON users USING GIN (LOWER(handle) gin_trgm_ops);
+CREATE INDEX users_display_name_trgm_idx ON users USING GIN (LOWER(display_name) gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX users_display_name_trgm_idx;
+DROP INDEX users_handle_trgm_idx;
+DROP INDEX users_display_name_prefix_idx;
+DROP INDEX users_handle_prefix_idx;
+DROP INDEX users_follower_count_idx;
+DROP TRIGGER follows_count ON follows;
+DROP FUNCTION count_followers();
+ALTER TABLE users DROP COLUMN follower_count;
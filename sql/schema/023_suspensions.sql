@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN suspended_until TIMESTAMP,
+    ADD COLUMN suspension_reason TEXT;
+
+-- Partial index for hiding suspended users' chirps from listings; only a
+-- handful of users are ever suspended at once.
+CREATE INDEX users_suspended_until_idx ON users (suspended_until)
+    WHERE suspended_until IS NOT NULL;
+
+-- A banned domain also covers its subdomains.
+CREATE TABLE banned_email_domains (
+    domain TEXT PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE banned_email_domains;
+DROP INDEX users_suspended_until_idx;
+ALTER TABLE users
+    DROP COLUMN suspension_reason,
+    DROP COLUMN suspended_until;
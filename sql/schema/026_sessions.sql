@@ -0,0 +1,19 @@
+-- +goose Up
+-- A session is a refresh token family; its live token records the client
+-- that last refreshed it.
+ALTER TABLE refresh_tokens
+    ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN ip_address TEXT NOT NULL DEFAULT '',
+    ADD COLUMN last_used_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+UPDATE refresh_tokens SET last_used_at = updated_at;
+
+CREATE INDEX refresh_tokens_live_user_id_idx ON refresh_tokens (user_id)
+    WHERE revoked_at IS NULL;
+
+-- +goose Down
+DROP INDEX refresh_tokens_live_user_id_idx;
+ALTER TABLE refresh_tokens
+    DROP COLUMN last_used_at,
+    DROP COLUMN ip_address,
+    DROP COLUMN user_agent;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Deleting a thread's root used to cascade to every reply in the thread,
+-- including other users' replies, without publishing their deletion.
+-- Replies now outlive the root like they outlive their direct parent, and a
+-- reply without a thread is treated as the root of its own.
+ALTER TABLE chirps
+    DROP CONSTRAINT chirps_thread_id_fkey,
+    ADD CONSTRAINT chirps_thread_id_fkey
+        FOREIGN KEY (thread_id) REFERENCES chirps(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE chirps
+    DROP CONSTRAINT chirps_thread_id_fkey,
+    ADD CONSTRAINT chirps_thread_id_fkey
+        FOREIGN KEY (thread_id) REFERENCES chirps(id) ON DELETE CASCADE;
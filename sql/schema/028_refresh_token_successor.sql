@@ -0,0 +1,8 @@
+-- +goose Up
+-- The token a rotation replaced each token with, so a client that races
+-- itself can be handed the same successor instead of tripping reuse
+-- detection.
+ALTER TABLE refresh_tokens ADD COLUMN replaced_by TEXT;
+
+-- +goose Down
+ALTER TABLE refresh_tokens DROP COLUMN replaced_by;
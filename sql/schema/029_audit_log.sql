@@ -0,0 +1,19 @@
+-- +goose Up
+-- Moderation and role changes, kept so they can be reviewed and so users
+-- can see in their export what was done to their account. actor_id is NULL
+-- for changes made from the command line, or by an account since deleted.
+CREATE TABLE audit_log (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    subject_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action TEXT NOT NULL,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    details JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_log_subject_id_idx ON audit_log (subject_id, created_at);
+CREATE INDEX audit_log_actor_id_idx ON audit_log (actor_id, created_at);
+
+-- +goose Down
+DROP TABLE audit_log;
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/stream"
+)
+
+const sseHeartbeatInterval = 15 * time.Second
+
+type chirpDeletedEvent struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type notification struct {
+	Type       string    `json:"type"`
+	FromUserID uuid.UUID `json:"from_user_id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+}
+
+// threadRoot returns the ID of the chirp that started the chirp's thread. A
+// chirp that isn't a reply is the root of its own thread.
+func threadRoot(chirp database.Chirp) uuid.UUID {
+	if chirp.ThreadID.Valid {
+		return chirp.ThreadID.UUID
+	}
+	return chirp.ID
+}
+
+func (c *apiConfig) publishChirpCreated(chirp database.Chirp) {
+	JSONChirp, err := createResponseStruct(chirp)
+	if err != nil {
+		log.Printf("Error creating chirp event: %v", err)
+		return
+	}
+	c.events.Publish(stream.Event{
+		Type:     stream.EventChirp,
+		AuthorID: chirp.UserID,
+		ThreadID: threadRoot(chirp),
+		Data:     JSONChirp,
+	})
+}
+
+func (c *apiConfig) publishChirpDeleted(chirp database.Chirp) {
+	c.events.Publish(stream.Event{
+		Type:     stream.EventDelete,
+		AuthorID: chirp.UserID,
+		ThreadID: threadRoot(chirp),
+		Data:     chirpDeletedEvent{ID: chirp.ID, UserID: chirp.UserID},
+	})
+}
+
+func (c *apiConfig) publishNotification(recipientID uuid.UUID, n notification) {
+	if recipientID == n.FromUserID {
+		return
+	}
+	c.events.Publish(stream.Event{
+		Type:        stream.EventNotification,
+		AuthorID:    n.FromUserID,
+		RecipientID: recipientID,
+		Data:        n,
+	})
+}
+
+func (c *apiConfig) StreamChirps(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Like getViewer, but the claims are kept so the session can be
+	// rechecked while the stream is open.
+	var claims auth.Claims
+	var err error
+	if r.Header.Get("Authorization") != "" {
+		claims, err = c.getLoggedInClaims(r)
+		if err != nil {
+			respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	viewerID := claims.UserID
+	hidden, err := c.trackHidden(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve hidden authors", http.StatusInternalServerError)
+		log.Printf("Error retrieving hidden authors: %v", err)
+		return
+	}
+
+	authorID := uuid.Nil
+	if id := r.URL.Query().Get("author_id"); id != "" {
+		authorID, err = uuid.Parse(id)
+		if err != nil {
+			respondWithError(w, "Invalid author ID", http.StatusBadRequest)
+			return
+		}
+	}
+	// Hidden authors are checked as each event is written rather than in
+	// the filter, so that blocks and mutes made while the stream is open
+	// apply to it.
+	filter := func(e stream.Event) bool {
+		return e.IsTimeline() && (authorID == uuid.Nil || e.AuthorID == authorID)
+	}
+	writeEvent := func(event stream.Event) error {
+		isHidden, err := hidden.hides(r.Context(), event.AuthorID)
+		if err != nil {
+			log.Printf("Error retrieving hidden authors: %v", err)
+			return err
+		}
+		if isHidden {
+			return nil
+		}
+		return writeSSEEvent(w, event)
+	}
+
+	var lastEventID uint64
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		parsed, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			respondWithError(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastEventID = parsed
+	}
+
+	sub := c.events.Subscribe(lastEventID, filter)
+	defer c.events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for _, event := range sub.Replay {
+		if err := writeEvent(event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.stopping:
+			// Clients reconnect with Last-Event-ID once the server is back.
+			return
+		case <-heartbeat.C:
+			// Logging out or being suspended ends the stream; the client
+			// can reconnect anonymously if it wants the public feed.
+			if viewerID != uuid.Nil && !c.stillAuthorized(r.Context(), claims) {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with
+				// Last-Event-ID to pick up where it left off.
+				return
+			}
+			if err := writeEvent(event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSEEvent(w http.ResponseWriter, event stream.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		log.Printf("Error encoding stream event: %v", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+const maxSuspensionReasonLength = 500
+
+var errAccountSuspended = errors.New("account is suspended")
+
+func isSuspended(user database.User) bool {
+	return user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now())
+}
+
+func suspensionMessage(user database.User) string {
+	return fmt.Sprintf("Account suspended until %s: %s",
+		user.SuspendedUntil.Time.UTC().Format(time.RFC3339), user.SuspensionReason.String)
+}
+
+// SuspendUser suspends a user until the given time. Their refresh tokens are
+// revoked and their access tokens stop working straight away, and their
+// chirps are hidden from listings until the suspension ends. Suspending an
+// already suspended user replaces the end time and reason. Only users ranked
+// below the caller can be suspended.
+func (c *apiConfig) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	caller, err := c.getLoggedInAccount(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var p suspensionParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, "Failed to decode suspension", http.StatusBadRequest)
+		return
+	}
+	p.Reason = strings.TrimSpace(p.Reason)
+	fieldErrors := make(map[string]string)
+	if p.Until == nil || !p.Until.After(time.Now()) {
+		fieldErrors["until"] = "must be a time in the future"
+	}
+	if p.Reason == "" {
+		fieldErrors["reason"] = "is required"
+	} else if utf8.RuneCountInString(p.Reason) > maxSuspensionReasonLength {
+		fieldErrors["reason"] = "is too long"
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, "Invalid suspension", fieldErrors)
+		return
+	}
+
+	tx, err := c.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, "Failed to suspend user", http.StatusInternalServerError)
+		log.Printf("Error starting transaction: %v", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
+
+	_, err = lockTarget(r.Context(), qtx, caller, userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errOutranked) {
+		respondWithError(w, "You cannot suspend a user ranked at or above you", http.StatusForbidden)
+		return
+	}
+	var user database.User
+	if err == nil {
+		user, err = qtx.SuspendUser(r.Context(), database.SuspendUserParams{
+			ID:               userID,
+			SuspendedUntil:   sql.NullTime{Time: p.Until.UTC(), Valid: true},
+			SuspensionReason: sql.NullString{String: p.Reason, Valid: true},
+		})
+	}
+	if err == nil {
+		err = qtx.RevokeAllRefreshTokens(r.Context(), userID)
+	}
+	if err == nil {
+		err = recordAudit(r.Context(), qtx, database.CreateAuditEntryParams{
+			ActorID:   actor(caller.ID),
+			SubjectID: userID,
+			Action:    auditSuspend,
+		}, auditSuspension{Until: user.SuspendedUntil.Time, Reason: p.Reason})
+	}
+	if err != nil {
+		respondWithError(w, "Failed to suspend user", http.StatusInternalServerError)
+		log.Printf("Error suspending user: %v", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, "Failed to suspend user", http.StatusInternalServerError)
+		log.Printf("Error committing suspension: %v", err)
+		return
+	}
+	c.respondWithUser(w, user)
+}
+
+// UnsuspendUser lifts a suspension early. The user has to log in again.
+func (c *apiConfig) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	caller, err := c.getLoggedInAccount(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := c.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, "Failed to unsuspend user", http.StatusInternalServerError)
+		log.Printf("Error starting transaction: %v", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
+
+	_, err = lockTarget(r.Context(), qtx, caller, userID)
+	var user database.User
+	if err == nil {
+		user, err = qtx.UnsuspendUser(r.Context(), userID)
+	}
+	if err == nil {
+		err = recordAudit(r.Context(), qtx, database.CreateAuditEntryParams{
+			ActorID:   actor(caller.ID),
+			SubjectID: userID,
+			Action:    auditUnsuspend,
+		}, struct{}{})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	switch {
+	case err == nil:
+		c.respondWithUser(w, user)
+	case err == sql.ErrNoRows:
+		respondWithError(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, errOutranked):
+		respondWithError(w, "You cannot unsuspend a user ranked at or above you", http.StatusForbidden)
+	default:
+		respondWithError(w, "Failed to unsuspend user", http.StatusInternalServerError)
+		log.Printf("Error unsuspending user: %v", err)
+	}
+}
+
+func (c *apiConfig) respondWithUser(w http.ResponseWriter, user database.User) {
+	JSONUser, err := c.userResponse(user)
+	if err != nil {
+		respondWithError(w, "Failed to create user response", http.StatusInternalServerError)
+		log.Printf("Error creating user response: %v", err)
+		return
+	}
+	respondWithJSON(w, JSONUser, http.StatusOK)
+}
+
+// normalizeDomain accepts a bare domain such as example.com, optionally
+// written as @example.com, and returns it lowercased.
+func normalizeDomain(domain string) (string, bool) {
+	domain = strings.TrimPrefix(strings.TrimSpace(domain), "@")
+	if domain == "" || strings.Contains(domain, "@") {
+		return "", false
+	}
+	email, ok := normalizeEmail("postmaster@" + domain)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimPrefix(email, "postmaster@"), true
+}
+
+// emailDomainBanned reports whether email, which must already be
+// normalized, belongs to a banned domain or one of its subdomains.
+func (c *apiConfig) emailDomainBanned(ctx context.Context, email string) (bool, error) {
+	return c.dbQueries.IsEmailDomainBanned(ctx, email[strings.LastIndex(email, "@")+1:])
+}
+
+func (c *apiConfig) GetBannedDomains(w http.ResponseWriter, r *http.Request) {
+	domains, err := c.dbQueries.GetBannedEmailDomains(r.Context())
+	if err != nil {
+		respondWithError(w, "Failed to retrieve banned domains", http.StatusInternalServerError)
+		log.Printf("Error retrieving banned domains: %v", err)
+		return
+	}
+	response := []bannedDomain{}
+	for _, d := range domains {
+		response = append(response, bannedDomain{Domain: d.Domain, Reason: d.Reason, CreatedAt: d.CreatedAt})
+	}
+	respondWithJSON(w, response, http.StatusOK)
+}
+
+// BanDomain stops new registrations, and email changes, to addresses at a
+// domain and its subdomains. Existing accounts are not affected.
+func (c *apiConfig) BanDomain(w http.ResponseWriter, r *http.Request) {
+	var p bannedDomainParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, "Failed to decode domain ban", http.StatusBadRequest)
+		return
+	}
+	domain, ok := normalizeDomain(p.Domain)
+	if !ok {
+		respondWithFieldErrors(w, "Invalid domain ban", map[string]string{"domain": "must be a domain name such as example.com"})
+		return
+	}
+	ban, err := c.dbQueries.BanEmailDomain(r.Context(), database.BanEmailDomainParams{
+		Domain: domain,
+		Reason: strings.TrimSpace(p.Reason),
+	})
+	if err != nil {
+		respondWithError(w, "Failed to ban domain", http.StatusInternalServerError)
+		log.Printf("Error banning domain: %v", err)
+		return
+	}
+	respondWithJSON(w, bannedDomain{Domain: ban.Domain, Reason: ban.Reason, CreatedAt: ban.CreatedAt}, http.StatusCreated)
+}
+
+func (c *apiConfig) UnbanDomain(w http.ResponseWriter, r *http.Request) {
+	domain, ok := normalizeDomain(r.PathValue("domain"))
+	if !ok {
+		respondWithError(w, "Invalid domain", http.StatusBadRequest)
+		return
+	}
+	removed, err := c.dbQueries.UnbanEmailDomain(r.Context(), domain)
+	if err != nil {
+		respondWithError(w, "Failed to unban domain", http.StatusInternalServerError)
+		log.Printf("Error unbanning domain: %v", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, "Domain is not banned", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,57 +1,320 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/password"
 )
 
 type chirpParams struct {
-	Body string `json:"body"`
+	Body           string     `json:"body"`
+	ReplyToID      *uuid.UUID `json:"reply_to_id"`
+	ContentWarning string     `json:"content_warning"`
+	Sensitive      bool       `json:"sensitive"`
+	ExpiresIn      *int32     `json:"expires_in"`
 }
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Body           string     `json:"body"`
+	UserID         uuid.UUID  `json:"user_id"`
+	ReplyToID      *uuid.UUID `json:"reply_to_id,omitempty"`
+	Author         *Author    `json:"author,omitempty"`
+	ContentWarning string     `json:"content_warning,omitempty"`
+	Sensitive      bool       `json:"sensitive"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	ExpiresIn      *int64     `json:"expires_in,omitempty"`
+}
+
+// Author is the compact profile embedded in chirps with expand=author.
+type Author struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+}
+
+type contentWarningParams struct {
+	ContentWarning string `json:"content_warning"`
+	Sensitive      bool   `json:"sensitive"`
 }
 
 type ValidationError struct {
 	Error string `json:"error"`
 }
 
+type FieldValidationError struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields"`
+	// PasswordRules lists each password policy rule that failed.
+	PasswordRules []password.Violation `json:"password_rules,omitempty"`
+}
+
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID               uuid.UUID `json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Email            string    `json:"email"`
+	EmailVerified    bool      `json:"email_verified"`
+	PendingEmail     string    `json:"pending_email,omitempty"`
+	Handle           string    `json:"handle"`
+	DisplayName      string    `json:"display_name"`
+	Bio              string    `json:"bio"`
+	Location         string    `json:"location"`
+	Website          string    `json:"website"`
+	AvatarURL        string    `json:"avatar_url,omitempty"`
+	BannerURL        string    `json:"banner_url,omitempty"`
+	IsChirpyRed      bool      `json:"is_chirpy_red"`
+	SensitiveContent string    `json:"sensitive_content"`
+	// DeletionScheduledAt is when the account will be deleted, if the user
+	// has asked for that and hasn't logged in since.
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	SuspendedUntil      *time.Time `json:"suspended_until,omitempty"`
+	SuspensionReason    string     `json:"suspension_reason,omitempty"`
+	Role                string     `json:"role"`
+}
+
+// profileParams is a partial update: omitted fields are left unchanged and
+// an empty string clears a field.
+type profileParams struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	Location    *string `json:"location"`
+	Website     *string `json:"website"`
+}
+
+// userUpdateParams is a partial update of the logged-in user. Changing the
+// email or password needs the current password.
+type userUpdateParams struct {
+	profileParams
+	Email           *string `json:"email"`
+	Password        *string `json:"password"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+type deleteAccountParams struct {
+	Password string `json:"password"`
+}
+
+type suspensionParams struct {
+	Until  *time.Time `json:"until"`
+	Reason string     `json:"reason"`
+}
+
+type bannedDomainParams struct {
+	Domain string `json:"domain"`
+	Reason string `json:"reason"`
+}
+
+type bannedDomain struct {
+	Domain    string    `json:"domain"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type roleParams struct {
+	Role string `json:"role"`
+}
+
+type preferencesParams struct {
+	SensitiveContent string `json:"sensitive_content"`
 }
 
 type userParams struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Handle   string `json:"handle"`
+}
+
+// Profile is the public view of a user. It must never include the email.
+type Profile struct {
+	ID             uuid.UUID `json:"id"`
+	Handle         string    `json:"handle"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	Location       string    `json:"location"`
+	Website        string    `json:"website"`
+	AvatarURL      string    `json:"avatar_url,omitempty"`
+	BannerURL      string    `json:"banner_url,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	FollowersCount int64     `json:"followers_count"`
+	FollowingCount int64     `json:"following_count"`
+}
+
+// directoryEntry is a search result. Like Profile, it never includes the
+// email.
+type directoryEntry struct {
+	ID             uuid.UUID `json:"id"`
+	Handle         string    `json:"handle"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	AvatarURL      string    `json:"avatar_url,omitempty"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	FollowersCount int64     `json:"followers_count"`
+}
+
+type directoryResponse struct {
+	Users      []directoryEntry `json:"users"`
+	NextCursor string           `json:"next_cursor,omitempty"`
 }
 
 type loginResponse struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
-	AccessToken  string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Handle        string    `json:"handle"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Role          string    `json:"role"`
+	AccessToken   string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
 }
 
-type refreshResponse struct {
+type verifyEmailParams struct {
 	Token string `json:"token"`
 }
 
+type forgotPasswordParams struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordParams struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type refreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
 type chirpyRedEvent struct {
 	Event string `json:"event"`
 	Data  struct {
 		UserID string `json:"user_id"`
 	} `json:"data"`
 }
+
+type followEntry struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type followListResponse struct {
+	Count      int64         `json:"count"`
+	Users      []followEntry `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type timelineResponse struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+type chirpStats struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Views     int64     `json:"views"`
+	Likes     int64     `json:"likes"`
+	Replies   int64     `json:"replies"`
+}
+
+type dailyStats struct {
+	Date    string `json:"date"`
+	Views   int64  `json:"views"`
+	Likes   int64  `json:"likes"`
+	Replies int64  `json:"replies"`
+}
+
+type analyticsResponse struct {
+	Chirps     []chirpStats `json:"chirps"`
+	Daily      []dailyStats `json:"daily"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+type relationshipParams struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type relationshipEntry struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type dataExport struct {
+	ID          uuid.UUID  `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+type chirpActivity struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportFollows struct {
+	Following []relationshipEntry `json:"following"`
+	Followers []relationshipEntry `json:"followers"`
+}
+
+// exportAuditEntry leaves out who made the change, so an export doesn't
+// name the moderators who acted on the account. UserID is the account the
+// change was made to, which differs from the exporting user's for changes
+// they made as a moderator.
+type exportAuditEntry struct {
+	Action    string          `json:"action"`
+	CreatedAt time.Time       `json:"created_at"`
+	UserID    uuid.UUID       `json:"user_id"`
+	ChirpID   *uuid.UUID      `json:"chirp_id,omitempty"`
+	Details   json.RawMessage `json:"details"`
+}
+
+type auditSuspension struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+type auditRoleChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type auditContentWarning struct {
+	ContentWarning string `json:"content_warning,omitempty"`
+	Sensitive      bool   `json:"sensitive"`
+}
+
+type wsTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+type exportSession struct {
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+}
+
+// session is a login, which lasts across refreshes until it is logged out,
+// revoked or expires.
+type session struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	SignedInAt time.Time `json:"signed_in_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"log"
+	"maps"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/conditional"
 	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/handle"
+	"github.com/tbirddv/chirpy/internal/password"
 )
 
 func (c *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
@@ -18,6 +25,34 @@ func (c *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Error decoding user params: %v", err)
 		return
 	}
+	fieldErrors := make(map[string]string)
+	email, ok := normalizeEmail(p.Email)
+	if !ok {
+		fieldErrors["email"] = "must be a valid email address"
+	}
+	if err := handle.Validate(p.Handle); err != nil {
+		fieldErrors["handle"] = err.Error()
+	}
+	rules, err := c.checkPassword(p.Password, fieldErrors)
+	if err != nil {
+		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		log.Printf("Error checking password: %v", err)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		respondWithPasswordErrors(w, "Invalid user", fieldErrors, rules)
+		return
+	}
+	banned, err := c.emailDomainBanned(r.Context(), email)
+	if err != nil {
+		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		log.Printf("Error checking email domain: %v", err)
+		return
+	}
+	if banned {
+		respondWithError(w, "Registration is not allowed from this email domain", http.StatusForbidden)
+		return
+	}
 	hashedPassword, err := auth.HashPassword(p.Password)
 	if err != nil {
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
@@ -25,68 +60,335 @@ func (c *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	createUserParams := database.CreateUserParams{
-		Email:          p.Email,
+		Email:          email,
 		HashedPassword: hashedPassword,
+		Handle:         p.Handle,
 	}
 	user, err := c.dbQueries.CreateUser(r.Context(), createUserParams)
 	if err != nil {
+		if constraint, ok := uniqueViolation(err); ok {
+			if constraint == "users_handle_lower_idx" {
+				respondWithError(w, "Handle is already taken", http.StatusConflict)
+			} else {
+				respondWithError(w, "Email is already registered", http.StatusConflict)
+			}
+			return
+		}
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		log.Printf("Error creating user: %v", err)
 		return
 	}
+	c.sendVerificationEmail(user, user.Email)
 
-	JSONUser, err := createResponseStruct(user)
+	JSONUser, err := c.userResponse(user)
 	if err != nil {
 		http.Error(w, "Failed to create user response", http.StatusInternalServerError)
 		log.Printf("Error creating user response: %v", err)
 		return
 	}
 
+	if etag, err := representationETag(JSONUser); err == nil {
+		w.Header().Set("ETag", etag)
+	}
 	respondWithJSON(w, JSONUser, http.StatusCreated)
 }
 
+// lookupUser finds a user by handle or, failing that, by ID.
+func (c *apiConfig) lookupUser(ctx context.Context, handleOrID string) (database.User, error) {
+	if id, err := uuid.Parse(handleOrID); err == nil {
+		return c.dbQueries.GetUserByID(ctx, id)
+	}
+	if !handle.Valid(handleOrID) {
+		return database.User{}, sql.ErrNoRows
+	}
+	return c.dbQueries.GetUserByHandle(ctx, handleOrID)
+}
+
+func (c *apiConfig) GetProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := c.lookupUser(r.Context(), strings.TrimPrefix(r.PathValue("handle"), "@"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+
+	profile := Profile{
+		ID:          user.ID,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Location:    user.Location,
+		Website:     user.Website,
+		AvatarURL:   c.mediaURL(user.AvatarKey),
+		BannerURL:   c.mediaURL(user.BannerKey),
+		CreatedAt:   user.CreatedAt,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+	profile.FollowersCount, err = c.dbQueries.CountFollowers(r.Context(), user.ID)
+	if err == nil {
+		profile.FollowingCount, err = c.dbQueries.CountFollowing(r.Context(), user.ID)
+	}
+	if err != nil {
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error counting follows: %v", err)
+		return
+	}
+	respondWithJSON(w, profile, http.StatusOK)
+}
+
+// getCurrentUser returns the logged-in user with the ETag that PATCH
+// /api/users/me takes in If-Match. There is no Last-Modified because not
+// every change to the user moves updated_at.
+func (c *apiConfig) getCurrentUser(w http.ResponseWriter, r *http.Request) {
+	user, err := c.getLoggedInAccount(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	JSONUser, err := c.userResponse(user)
+	if err != nil {
+		respondWithError(w, "Failed to create user response", http.StatusInternalServerError)
+		log.Printf("Error creating user response: %v", err)
+		return
+	}
+	respondWithConditionalJSON(w, r, JSONUser, time.Time{})
+}
+
+// updateUser applies a partial update to the logged-in user. A new email
+// address doesn't replace the current one until it has been verified.
 func (c *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
-	UserID, err := c.getLoggedInUser(r)
+	userID, err := c.getLoggedInUser(r)
 	if err != nil {
-		http.Error(w, "Failed to get logged in user", http.StatusUnauthorized)
-		log.Printf("Error getting logged in user: %v", err)
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	var p userParams
+	var p userUpdateParams
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, "Failed to update user", http.StatusBadRequest)
-		log.Printf("Error decoding user params: %v", err)
+		respondWithError(w, "Failed to decode user update", http.StatusBadRequest)
 		return
 	}
+	c.applyUserUpdate(w, r, userID, p, make(map[string]string))
+}
 
-	hashedPassword, err := auth.HashPassword(p.Password)
+// replaceUser is the deprecated PUT /api/users. It keeps its original
+// contract of setting both the email and the password, and only those,
+// though it now asks for current_password like PATCH /api/users/me does.
+func (c *apiConfig) replaceUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
 	if err != nil {
-		http.Error(w, "Failed to update user", http.StatusInternalServerError)
-		log.Printf("Error hashing password: %v", err)
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	updateUserParams := database.UpdateUserParams{
-		ID:             UserID,
-		Email:          p.Email,
-		HashedPassword: hashedPassword,
+	var p userUpdateParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, "Failed to decode user update", http.StatusBadRequest)
+		return
+	}
+	p.profileParams = profileParams{}
+	fieldErrors := make(map[string]string)
+	if p.Email == nil {
+		fieldErrors["email"] = "is required"
+	}
+	if p.Password == nil {
+		fieldErrors["password"] = "is required"
+	}
+	c.applyUserUpdate(w, r, userID, p, fieldErrors)
+}
+
+// applyUserUpdate validates and applies p, reporting any problems along
+// with those the caller has already found in fieldErrors.
+func (c *apiConfig) applyUserUpdate(w http.ResponseWriter, r *http.Request, userID uuid.UUID, p userUpdateParams, fieldErrors map[string]string) {
+	maps.Copy(fieldErrors, validateProfile(&p.profileParams))
+	if p.Email != nil {
+		email, ok := normalizeEmail(*p.Email)
+		if !ok {
+			fieldErrors["email"] = "must be a valid email address"
+		}
+		*p.Email = email
+	}
+	var rules []password.Violation
+	var err error
+	if p.Password != nil {
+		rules, err = c.checkPassword(*p.Password, fieldErrors)
+		if err != nil {
+			respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+			log.Printf("Error checking password: %v", err)
+			return
+		}
+	}
+	changesCredentials := p.Email != nil || p.Password != nil
+	if changesCredentials && p.CurrentPassword == "" {
+		fieldErrors["current_password"] = "is required to change the email or password"
+	}
+	if len(fieldErrors) > 0 {
+		respondWithPasswordErrors(w, "Invalid user", fieldErrors, rules)
+		return
+	}
+	if p.Email != nil {
+		banned, err := c.emailDomainBanned(r.Context(), *p.Email)
+		if err != nil {
+			respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+			log.Printf("Error checking email domain: %v", err)
+			return
+		}
+		if banned {
+			respondWithError(w, "Email addresses at this domain are not allowed", http.StatusForbidden)
+			return
+		}
 	}
 
-	user, err := c.dbQueries.UpdateUser(r.Context(), updateUserParams)
+	var hashedPassword string
+	if p.Password != nil {
+		hashedPassword, err = auth.HashPassword(*p.Password)
+		if err != nil {
+			respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+			log.Printf("Error hashing password: %v", err)
+			return
+		}
+	}
+
+	tx, err := c.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		http.Error(w, "Failed to update user", http.StatusInternalServerError)
-		log.Printf("Error updating user: %v", err)
+		respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+		log.Printf("Error starting transaction: %v", err)
 		return
 	}
+	defer tx.Rollback()
+	qtx := c.dbQueries.WithTx(tx)
 
-	JSONUser, err := createResponseStruct(user)
+	current, err := qtx.GetUserByIDForUpdate(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "Failed to create user response", http.StatusInternalServerError)
+		respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+	currentUser, err := c.userResponse(current)
+	if err != nil {
+		respondWithError(w, "Failed to update user", http.StatusInternalServerError)
 		log.Printf("Error creating user response: %v", err)
 		return
 	}
+	etag, err := representationETag(currentUser)
+	if err != nil {
+		respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+		log.Printf("Error computing ETag: %v", err)
+		return
+	}
+	if conditional.PreconditionFailed(r, etag) {
+		respondWithError(w, "User has been modified", http.StatusPreconditionFailed)
+		return
+	}
+	if changesCredentials && auth.CheckPasswordHash(p.CurrentPassword, current.HashedPassword) != nil {
+		respondWithError(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
 
+	if p.Password != nil {
+		err = qtx.UpdatePassword(r.Context(), database.UpdatePasswordParams{
+			ID:             userID,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+			log.Printf("Error updating password: %v", err)
+			return
+		}
+	}
+	if p.Email != nil {
+		// Asking for the current address cancels a pending change.
+		var pending sql.NullString
+		if *p.Email != current.Email {
+			pending = sql.NullString{String: *p.Email, Valid: true}
+		}
+		err = qtx.SetPendingEmail(r.Context(), database.SetPendingEmailParams{
+			ID:           userID,
+			PendingEmail: pending,
+		})
+		if err != nil {
+			respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+			log.Printf("Error setting pending email: %v", err)
+			return
+		}
+	}
+	user, err := qtx.UpdateProfile(r.Context(), database.UpdateProfileParams{
+		ID:          userID,
+		DisplayName: nullString(p.DisplayName),
+		Bio:         nullString(p.Bio),
+		Location:    nullString(p.Location),
+		Website:     nullString(p.Website),
+	})
+	if err != nil {
+		respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+		log.Printf("Error updating profile: %v", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, "Failed to update user", http.StatusInternalServerError)
+		log.Printf("Error committing user update: %v", err)
+		return
+	}
+	if p.Email != nil && user.PendingEmail.Valid {
+		c.sendVerificationEmail(user, user.PendingEmail.String)
+	}
+
+	JSONUser, err := c.userResponse(user)
+	if err != nil {
+		respondWithError(w, "Failed to create user response", http.StatusInternalServerError)
+		log.Printf("Error creating user response: %v", err)
+		return
+	}
+
+	if etag, err := representationETag(JSONUser); err == nil {
+		w.Header().Set("ETag", etag)
+	}
+	respondWithJSON(w, JSONUser, http.StatusOK)
+}
+
+func (c *apiConfig) updatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		http.Error(w, "Failed to get logged in user", http.StatusUnauthorized)
+		log.Printf("Error getting logged in user: %v", err)
+		return
+	}
+
+	var p preferencesParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Failed to update preferences", http.StatusBadRequest)
+		log.Printf("Error decoding preferences: %v", err)
+		return
+	}
+	switch p.SensitiveContent {
+	case "expand", "warn", "hide":
+	default:
+		respondWithFieldErrors(w, "Invalid preferences", map[string]string{
+			"sensitive_content": "must be one of expand, warn or hide",
+		})
+		return
+	}
+
+	user, err := c.dbQueries.UpdateSensitiveContent(r.Context(), database.UpdateSensitiveContentParams{
+		ID:               userID,
+		SensitiveContent: p.SensitiveContent,
+	})
+	if err != nil {
+		http.Error(w, "Failed to update preferences", http.StatusInternalServerError)
+		log.Printf("Error updating preferences: %v", err)
+		return
+	}
+
+	JSONUser, err := c.userResponse(user)
+	if err != nil {
+		http.Error(w, "Failed to create user response", http.StatusInternalServerError)
+		log.Printf("Error creating user response: %v", err)
+		return
+	}
 	respondWithJSON(w, JSONUser, http.StatusOK)
 }
 
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbirddv/chirpy/internal/auth"
+)
+
+func TestReplaceUserRequiresEmailAndPassword(t *testing.T) {
+	c := testConfig(t)
+	user, familyID, _ := startSession(t, c)
+	token, err := auth.MakeJWT(user.ID, auth.Role(user.Role), familyID, c.tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make access token: %v", err)
+	}
+
+	for _, body := range []string{`{"email":"new@example.com"}`, `{"password":"correct horse battery"}`} {
+		req := httptest.NewRequest(http.MethodPut, "/api/users", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c.replaceUser(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d: %s", body, rec.Code, rec.Body)
+		}
+	}
+}
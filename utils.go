@@ -2,15 +2,21 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/conditional"
 	"github.com/tbirddv/chirpy/internal/database"
+	"github.com/tbirddv/chirpy/internal/password"
 )
 
 func respondWithError(w http.ResponseWriter, message string, statusCode int) {
@@ -30,8 +36,39 @@ func respondWithError(w http.ResponseWriter, message string, statusCode int) {
 
 }
 
+func respondWithFieldErrors(w http.ResponseWriter, message string, fields map[string]string) {
+	respondWithPasswordErrors(w, message, fields, nil)
+}
+
+// respondWithPasswordErrors is respondWithFieldErrors plus the individual
+// password policy rules that failed.
+func respondWithPasswordErrors(w http.ResponseWriter, message string, fields map[string]string, rules []password.Violation) {
+	respondWithJSON(w, FieldValidationError{Error: message, Fields: fields, PasswordRules: rules}, http.StatusBadRequest)
+}
+
+// checkPassword applies the password policy. Failures are summarised under
+// fieldErrors["password"] and returned individually.
+func (c *apiConfig) checkPassword(pw string, fieldErrors map[string]string) ([]password.Violation, error) {
+	violations, err := c.passwordPolicy.Check(pw)
+	if err != nil {
+		return nil, err
+	}
+	if len(violations) > 0 {
+		messages := make([]string, len(violations))
+		for i, v := range violations {
+			messages[i] = v.Message
+		}
+		fieldErrors["password"] = strings.Join(messages, "; ")
+	}
+	return violations, nil
+}
+
 func respondWithJSON(w http.ResponseWriter, data interface{}, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
+	respondWithContentType(w, "application/json", data, statusCode)
+}
+
+func respondWithContentType(w http.ResponseWriter, contentType string, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", contentType)
 	var buf bytes.Buffer
 	err := json.NewEncoder(&buf).Encode(data)
 	if err != nil {
@@ -47,6 +84,87 @@ func respondWithJSON(w http.ResponseWriter, data interface{}, statusCode int) {
 
 }
 
+func encodeJSON(data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// representationETag returns the ETag a GET of data would carry, so writes
+// can check If-Match against exactly what clients were served.
+func representationETag(data interface{}) (string, error) {
+	body, err := encodeJSON(stableRepresentation(data))
+	if err != nil {
+		return "", err
+	}
+	return conditional.ETag(body), nil
+}
+
+// stableRepresentation drops fields that change by themselves, such as an
+// ephemeral chirp's expires_in countdown, so the ETag only moves when the
+// resource does. expires_at stays, so changing the expiry still counts.
+func stableRepresentation(data interface{}) interface{} {
+	switch v := data.(type) {
+	case Chirp:
+		v.ExpiresIn = nil
+		return v
+	case []Chirp:
+		stable := make([]Chirp, len(v))
+		for i, chirp := range v {
+			chirp.ExpiresIn = nil
+			stable[i] = chirp
+		}
+		return stable
+	}
+	return data
+}
+
+// deprecated wraps a route kept only for older clients, marking its
+// responses as deprecated and pointing at the route that replaces it.
+func deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next(w, r)
+	}
+}
+
+// respondWithConditionalJSON is respondWithJSON for cacheable reads: it sets
+// a strong ETag (and Last-Modified when known) and answers 304 when the
+// client's copy is still current.
+func respondWithConditionalJSON(w http.ResponseWriter, r *http.Request, data interface{}, lastModified time.Time) {
+	body, err := encodeJSON(data)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	etag, err := representationETag(data)
+	if err != nil {
+		log.Printf("Error computing ETag: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Add("Vary", "Authorization")
+
+	if conditional.NotModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
 func cleanProfanity(body string, badWords []string) string {
 	badWordsMap := make(map[string]struct{})
 
@@ -66,32 +184,134 @@ func cleanProfanity(body string, badWords []string) string {
 	return strings.Join(cleanedBody, " ")
 }
 
+// uniqueViolation returns the name of the unique constraint err violated,
+// if any.
+func uniqueViolation(err error) (string, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return pqErr.Constraint, true
+	}
+	return "", false
+}
+
 func (c *apiConfig) getLoggedInUser(r *http.Request) (uuid.UUID, error) {
-	token, err := auth.GetBearerToken(r.Header)
+	claims, err := c.getLoggedInClaims(r)
 	if err != nil {
 		return uuid.Nil, err
 	}
-	return auth.ValidateJWT(token, c.tokenSecret)
+	return claims.UserID, nil
+}
+
+func (c *apiConfig) getLoggedInClaims(r *http.Request) (auth.Claims, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	claims, _, err := c.authenticate(r.Context(), token)
+	return claims, err
+}
+
+// getLoggedInAccount is getLoggedInUser for handlers that need the caller's
+// current row, such as their role, rather than just their ID.
+func (c *apiConfig) getLoggedInAccount(r *http.Request) (database.User, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return database.User{}, err
+	}
+	_, user, err := c.authenticate(r.Context(), token)
+	return user, err
+}
+
+// authenticate validates an access token and checks that its user and the
+// session it was issued under are both still active.
+func (c *apiConfig) authenticate(ctx context.Context, token string) (auth.Claims, database.User, error) {
+	claims, err := auth.ParseJWT(token, c.tokenSecret)
+	if err != nil {
+		return auth.Claims{}, database.User{}, err
+	}
+	user, err := c.checkClaims(ctx, claims)
+	if err != nil {
+		return auth.Claims{}, database.User{}, err
+	}
+	return claims, user, nil
+}
+
+// checkClaims is the part of authenticate that can change after a token is
+// issued: whether its user and session are still active.
+func (c *apiConfig) checkClaims(ctx context.Context, claims auth.Claims) (database.User, error) {
+	user, err := c.checkActiveUser(ctx, claims.UserID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := c.checkSession(ctx, claims); err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+// checkActiveUser rejects users whose sessions have been shut down. Access
+// tokens stay valid until they expire, so revoking refresh tokens alone
+// would leave the user logged in for up to an hour.
+func (c *apiConfig) checkActiveUser(ctx context.Context, userID uuid.UUID) (database.User, error) {
+	user, err := c.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if user.DeletionScheduledAt.Valid {
+		return database.User{}, errAccountClosing
+	}
+	if isSuspended(user) {
+		return database.User{}, errAccountSuspended
+	}
+	return user, nil
 }
 
 func createResponseStruct(input interface{}) (any, error) {
 	switch v := input.(type) {
 	case database.User:
-		return User{
-			ID:          v.ID,
-			CreatedAt:   v.CreatedAt,
-			UpdatedAt:   v.UpdatedAt,
-			Email:       v.Email,
-			IsChirpyRed: v.IsChirpyRed,
-		}, nil
+		user := User{
+			ID:               v.ID,
+			CreatedAt:        v.CreatedAt,
+			UpdatedAt:        v.UpdatedAt,
+			Email:            v.Email,
+			EmailVerified:    v.EmailVerifiedAt.Valid,
+			PendingEmail:     v.PendingEmail.String,
+			Handle:           v.Handle,
+			DisplayName:      v.DisplayName,
+			Bio:              v.Bio,
+			Location:         v.Location,
+			Website:          v.Website,
+			IsChirpyRed:      v.IsChirpyRed,
+			SensitiveContent: v.SensitiveContent,
+			Role:             v.Role,
+		}
+		if v.DeletionScheduledAt.Valid {
+			user.DeletionScheduledAt = &v.DeletionScheduledAt.Time
+		}
+		if isSuspended(v) {
+			user.SuspendedUntil = &v.SuspendedUntil.Time
+			user.SuspensionReason = v.SuspensionReason.String
+		}
+		return user, nil
 	case database.Chirp:
-		return Chirp{
-			ID:        v.ID,
-			CreatedAt: v.CreatedAt,
-			UpdatedAt: v.UpdatedAt,
-			Body:      v.Body,
-			UserID:    v.UserID,
-		}, nil
+		chirp := Chirp{
+			ID:             v.ID,
+			CreatedAt:      v.CreatedAt,
+			UpdatedAt:      v.UpdatedAt,
+			Body:           v.Body,
+			UserID:         v.UserID,
+			ContentWarning: v.ContentWarning.String,
+			Sensitive:      v.Sensitive,
+		}
+		if v.ReplyToID.Valid {
+			chirp.ReplyToID = &v.ReplyToID.UUID
+		}
+		if v.ExpiresAt.Valid {
+			remaining := int64(max(time.Until(v.ExpiresAt.Time), 0) / time.Second)
+			chirp.ExpiresAt = &v.ExpiresAt.Time
+			chirp.ExpiresIn = &remaining
+		}
+		return chirp, nil
 	case []database.Chirp:
 		var chirps []Chirp
 		for _, c := range v {
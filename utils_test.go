@@ -0,0 +1,88 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/conditional"
+	"github.com/tbirddv/chirpy/internal/database"
+)
+
+func ephemeralChirp(t *testing.T, row database.Chirp) Chirp {
+	t.Helper()
+	chirp, err := createResponseStruct(row)
+	if err != nil {
+		t.Fatalf("Failed to create chirp response: %v", err)
+	}
+	return chirp.(Chirp)
+}
+
+func TestEphemeralChirpETagSurvivesCountdown(t *testing.T) {
+	now := time.Now()
+	row := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      "gone soon",
+		UserID:    uuid.New(),
+		ExpiresAt: sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+	}
+
+	rec := httptest.NewRecorder()
+	respondWithConditionalJSON(rec, httptest.NewRequest(http.MethodGet, "/", nil), ephemeralChirp(t, row), row.UpdatedAt)
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("Expected an ETag on the read")
+	}
+
+	// Long enough for expires_in to tick down at least once.
+	time.Sleep(1100 * time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	respondWithConditionalJSON(rec, req, ephemeralChirp(t, row), row.UpdatedAt)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for an unchanged ephemeral chirp, got %d", rec.Code)
+	}
+
+	current, err := representationETag(ephemeralChirp(t, row))
+	if err != nil {
+		t.Fatalf("Failed to compute ETag: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodDelete, "/", nil)
+	req.Header.Set("If-Match", etag)
+	if conditional.PreconditionFailed(req, current) {
+		t.Errorf("Expected If-Match with the ETag from the read to still match")
+	}
+
+	row.ExpiresAt.Time = row.ExpiresAt.Time.Add(time.Minute)
+	changed, err := representationETag(ephemeralChirp(t, row))
+	if err != nil {
+		t.Fatalf("Failed to compute ETag: %v", err)
+	}
+	if changed == etag {
+		t.Errorf("Expected changing expires_at to change the ETag")
+	}
+}
+
+func TestDeprecatedRouteStillServes(t *testing.T) {
+	handler := deprecated("/api/users/me", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPut, "/api/users", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected the wrapped handler's status, got %d", rec.Code)
+	}
+	if rec.Header().Get("Deprecation") != "true" {
+		t.Errorf("Expected a Deprecation header")
+	}
+	if link := rec.Header().Get("Link"); link != `</api/users/me>; rel="successor-version"` {
+		t.Errorf("Unexpected Link header %q", link)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/database"
+	chirpymail "github.com/tbirddv/chirpy/internal/mail"
+)
+
+const (
+	verifyEmailAction    = "verify-email"
+	verificationTokenTTL = 24 * time.Hour
+)
+
+// normalizeEmail checks that email is a bare RFC 5322 address such as
+// alice@example.com, without a display name or angle brackets, and returns
+// it trimmed with the domain lowercased. The local part keeps its case; the
+// database compares whole addresses case-insensitively.
+func normalizeEmail(email string) (string, bool) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", false
+	}
+	at := strings.LastIndex(email, "@")
+	domain := strings.ToLower(email[at+1:])
+	if !strings.Contains(domain, ".") {
+		return "", false
+	}
+	return email[:at+1] + domain, true
+}
+
+// sendVerificationEmail mails a token for POST /api/users/verify to address,
+// which is either the user's email or the one they are changing it to. It
+// runs in the background so a slow mail server never holds up the request;
+// failures are only logged and the user can ask for another token.
+func (c *apiConfig) sendVerificationEmail(user database.User, address string) {
+	// The token is bound to the address it was sent to, so it stops working
+	// if that address is no longer the user's email or pending email.
+	token, err := auth.MakeActionToken(user.ID, verifyEmailAction, address, c.tokenSecret, verificationTokenTTL)
+	if err != nil {
+		log.Printf("Error creating verification token: %v", err)
+		return
+	}
+	msg := chirpymail.Message{
+		To:      address,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf("Hi @%s,\n\n"+
+			"Confirm this address by sending the token below to POST %s/api/users/verify:\n\n"+
+			"%s\n\n"+
+			"The token expires in 24 hours. If you didn't sign up for Chirpy, ignore this email.\n",
+			user.Handle, c.baseURL, token),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := c.mailer.Send(ctx, msg); err != nil {
+			log.Printf("Error sending verification email: %v", err)
+		}
+	}()
+}
+
+func (c *apiConfig) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	var p verifyEmailParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, "Failed to decode verification", http.StatusBadRequest)
+		return
+	}
+	userID, email, err := auth.ValidateActionToken(p.Token, verifyEmailAction, c.tokenSecret)
+	if err != nil {
+		respondWithError(w, "Invalid or expired verification token", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.dbQueries.VerifyEmail(r.Context(), database.VerifyEmailParams{
+		ID:    userID,
+		Email: email,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, "Invalid or expired verification token", http.StatusBadRequest)
+		return
+	}
+	if _, ok := uniqueViolation(err); ok {
+		respondWithError(w, "Email address is already in use", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		respondWithError(w, "Failed to verify email", http.StatusInternalServerError)
+		log.Printf("Error verifying email: %v", err)
+		return
+	}
+
+	JSONUser, err := c.userResponse(user)
+	if err != nil {
+		respondWithError(w, "Failed to create user response", http.StatusInternalServerError)
+		log.Printf("Error creating user response: %v", err)
+		return
+	}
+	respondWithJSON(w, JSONUser, http.StatusOK)
+}
+
+func (c *apiConfig) resendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, err := c.getLoggedInUser(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user, err := c.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve user", http.StatusInternalServerError)
+		log.Printf("Error retrieving user: %v", err)
+		return
+	}
+	switch {
+	case user.PendingEmail.Valid:
+		c.sendVerificationEmail(user, user.PendingEmail.String)
+	case !user.EmailVerifiedAt.Valid:
+		c.sendVerificationEmail(user, user.Email)
+	default:
+		respondWithError(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// emailVerified reports whether the user may chirp under the instance's
+// verification policy.
+func (c *apiConfig) emailVerified(ctx context.Context, userID uuid.UUID) (bool, error) {
+	if !c.requireVerifiedEmail {
+		return true, nil
+	}
+	user, err := c.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt.Valid, nil
+}
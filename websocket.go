@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/stream"
+)
+
+const (
+	wsPingInterval   = 30 * time.Second
+	wsReadTimeout    = 2 * wsPingInterval
+	wsCloseTimeout   = 5 * time.Second
+	wsMaxMessageSize = 1 << 16
+	// wsTicketTTL is how long a client has to open the connection once
+	// it has a ticket.
+	wsTicketTTL = 30 * time.Second
+)
+
+const (
+	topicTimeline      = "timeline"
+	topicAuthor        = "author"
+	topicThread        = "thread"
+	topicNotifications = "notifications"
+)
+
+var (
+	errUnknownTopic   = errors.New("unknown topic")
+	errInvalidTopicID = errors.New("invalid topic ID")
+)
+
+type wsClientMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+	ID    string `json:"id,omitempty"`
+}
+
+type wsServerMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic,omitempty"`
+	ID    string `json:"id,omitempty"`
+	Event string `json:"event,omitempty"`
+	Seq   uint64 `json:"seq,omitempty"`
+	Data  any    `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type wsTopic struct {
+	name string
+	id   uuid.UUID
+}
+
+func (t wsTopic) idString() string {
+	if t.id == uuid.Nil {
+		return ""
+	}
+	return t.id.String()
+}
+
+// wsSession holds the topics one connection is subscribed to. matches is
+// used as the broker filter, so it runs on the publisher's goroutine.
+type wsSession struct {
+	userID uuid.UUID
+	mu     sync.Mutex
+	topics map[wsTopic]struct{}
+}
+
+func (s *wsSession) topicMatches(topic wsTopic, event stream.Event) bool {
+	switch topic.name {
+	case topicTimeline:
+		return event.IsTimeline()
+	case topicAuthor:
+		return event.IsTimeline() && event.AuthorID == topic.id
+	case topicThread:
+		return event.IsTimeline() && event.ThreadID == topic.id
+	case topicNotifications:
+		return event.Type == stream.EventNotification && event.RecipientID == s.userID
+	}
+	return false
+}
+
+func (s *wsSession) matchingTopics(event stream.Event) []wsTopic {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []wsTopic
+	for topic := range s.topics {
+		if s.topicMatches(topic, event) {
+			matched = append(matched, topic)
+		}
+	}
+	return matched
+}
+
+func (s *wsSession) matches(event stream.Event) bool {
+	return len(s.matchingTopics(event)) > 0
+}
+
+func parseTopic(msg wsClientMessage) (wsTopic, error) {
+	switch msg.Topic {
+	case topicTimeline, topicNotifications:
+		return wsTopic{name: msg.Topic}, nil
+	case topicAuthor, topicThread:
+		id, err := uuid.Parse(msg.ID)
+		if err != nil {
+			return wsTopic{}, errInvalidTopicID
+		}
+		return wsTopic{name: msg.Topic, id: id}, nil
+	}
+	return wsTopic{}, errUnknownTopic
+}
+
+// wsTickets holds the tickets browsers use to open a WebSocket, since they
+// can't set an Authorization header on one. A ticket stands in for the
+// access token it was issued against, works once and expires quickly, so
+// it does no harm if it ends up in a log with the rest of the URL.
+type wsTickets struct {
+	mu      sync.Mutex
+	tickets map[string]wsTicket
+}
+
+type wsTicket struct {
+	claims    auth.Claims
+	expiresAt time.Time
+}
+
+func (t *wsTickets) issue(claims auth.Claims) (string, error) {
+	ticket, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if t.tickets == nil {
+		t.tickets = make(map[string]wsTicket)
+	}
+	for key, unused := range t.tickets {
+		if now.After(unused.expiresAt) {
+			delete(t.tickets, key)
+		}
+	}
+	t.tickets[ticket] = wsTicket{claims: claims, expiresAt: now.Add(wsTicketTTL)}
+	return ticket, nil
+}
+
+// redeem returns the claims a ticket was issued against and forgets it.
+func (t *wsTickets) redeem(ticket string) (auth.Claims, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	issued, ok := t.tickets[ticket]
+	delete(t.tickets, ticket)
+	if !ok || time.Now().After(issued.expiresAt) {
+		return auth.Claims{}, false
+	}
+	return issued.claims, true
+}
+
+// IssueWebSocketTicket returns a ticket for opening GET /api/ws from a
+// browser.
+func (c *apiConfig) IssueWebSocketTicket(w http.ResponseWriter, r *http.Request) {
+	claims, err := c.getLoggedInClaims(r)
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ticket, err := c.wsTickets.issue(claims)
+	if err != nil {
+		respondWithError(w, "Failed to issue ticket", http.StatusInternalServerError)
+		log.Printf("Error issuing WebSocket ticket: %v", err)
+		return
+	}
+	respondWithJSON(w, wsTicketResponse{Ticket: ticket, ExpiresIn: int(wsTicketTTL.Seconds())}, http.StatusCreated)
+}
+
+// checkOrigin accepts WebSockets opened by pages served from BASE_URL, and
+// by clients other than browsers, which send no Origin. Without it any site
+// a logged-in user visits could open one with their ticket.
+func (c *apiConfig) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, base.Scheme) && strings.EqualFold(u.Host, base.Host)
+}
+
+// HandleWebSocket upgrades to a WebSocket carrying topic subscriptions. It
+// authenticates with the same access token as the REST API in the
+// Authorization header or, for browsers, with a ticket from
+// POST /api/ws/ticket in the ticket query parameter.
+func (c *apiConfig) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	var claims auth.Claims
+	var err error
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		var ok bool
+		claims, ok = c.wsTickets.redeem(ticket)
+		if !ok {
+			respondWithError(w, "Invalid or expired ticket", http.StatusUnauthorized)
+			return
+		}
+		// The session may have ended since the ticket was issued.
+		_, err = c.checkClaims(r.Context(), claims)
+	} else {
+		claims, err = c.getLoggedInClaims(r)
+	}
+	if err != nil {
+		respondWithError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hidden, err := c.trackHidden(r.Context(), claims.UserID)
+	if err != nil {
+		respondWithError(w, "Failed to retrieve hidden authors", http.StatusInternalServerError)
+		log.Printf("Error retrieving hidden authors: %v", err)
+		return
+	}
+
+	// On failure Upgrade has already written an HTTP error.
+	upgrader := websocket.Upgrader{CheckOrigin: c.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	// Any message or pong keeps an idle connection alive.
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	})
+
+	session := &wsSession{userID: claims.UserID, topics: make(map[wsTopic]struct{})}
+	sub := c.events.Subscribe(0, session.matches)
+	defer c.events.Unsubscribe(sub)
+
+	done := make(chan struct{})
+	defer close(done)
+	messages := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+			select {
+			case messages <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	expiry := time.NewTimer(time.Until(claims.ExpiresAt))
+	defer expiry.Stop()
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	closeAndWait := func(code int, reason string) {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsCloseTimeout))
+		select {
+		case <-readErr:
+		case <-time.After(wsCloseTimeout):
+		}
+	}
+
+	for {
+		select {
+		case <-readErr:
+			return
+		case <-c.stopping:
+			closeAndWait(websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-expiry.C:
+			closeAndWait(websocket.ClosePolicyViolation, "access token expired")
+			return
+		case <-ping.C:
+			// The token was checked at connect; logging out or being
+			// suspended has to end the connection too.
+			if !c.stillAuthorized(r.Context(), claims) {
+				closeAndWait(websocket.ClosePolicyViolation, "session ended")
+				return
+			}
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsCloseTimeout)); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				closeAndWait(websocket.CloseTryAgainLater, "client too slow")
+				return
+			}
+			// Checked here rather than in the filter so blocks and mutes
+			// made while the connection is open apply to it.
+			isHidden, err := hidden.hides(r.Context(), event.AuthorID)
+			if err != nil {
+				log.Printf("Error retrieving hidden authors: %v", err)
+				closeAndWait(websocket.CloseTryAgainLater, "server error")
+				return
+			}
+			if isHidden {
+				continue
+			}
+			for _, topic := range session.matchingTopics(event) {
+				err := conn.WriteJSON(wsServerMessage{
+					Type:  "event",
+					Topic: topic.name,
+					ID:    topic.idString(),
+					Event: event.Type,
+					Seq:   event.ID,
+					Data:  event.Data,
+				})
+				if err != nil {
+					return
+				}
+			}
+		case msg := <-messages:
+			reply := session.handleMessage(msg)
+			if err := conn.WriteJSON(reply); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *wsSession) handleMessage(raw []byte) wsServerMessage {
+	var msg wsClientMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return wsServerMessage{Type: "error", Error: "Invalid message"}
+	}
+
+	switch msg.Type {
+	case "ping":
+		return wsServerMessage{Type: "pong"}
+	case "subscribe", "unsubscribe":
+		topic, err := parseTopic(msg)
+		if err != nil {
+			return wsServerMessage{Type: "error", Topic: msg.Topic, ID: msg.ID, Error: err.Error()}
+		}
+		s.mu.Lock()
+		if msg.Type == "subscribe" {
+			s.topics[topic] = struct{}{}
+		} else {
+			delete(s.topics, topic)
+		}
+		s.mu.Unlock()
+		return wsServerMessage{Type: msg.Type + "d", Topic: topic.name, ID: topic.idString()}
+	}
+	return wsServerMessage{Type: "error", Error: "Unknown message type"}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/tbirddv/chirpy/internal/auth"
+	"github.com/tbirddv/chirpy/internal/stream"
+)
+
+func TestWebSocketSubscribe(t *testing.T) {
+	c := testConfig(t)
+	c.events = stream.NewBroker(10, 10)
+	user, familyID, _ := startSession(t, c)
+	token, err := auth.MakeJWT(user.ID, auth.Role(user.Role), familyID, c.tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to make access token: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(c.HandleWebSocket))
+	defer server.Close()
+
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(wsClientMessage{Type: "subscribe", Topic: topicTimeline}); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	var reply wsServerMessage
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	if reply.Type != "subscribed" || reply.Topic != topicTimeline {
+		t.Errorf("Expected a subscribed reply, got %+v", reply)
+	}
+}
+
+func TestWebSocketCheckOrigin(t *testing.T) {
+	c := &apiConfig{baseURL: "https://chirpy.example"}
+	tests := map[string]bool{
+		"":                            true,
+		"https://chirpy.example":      true,
+		"https://CHIRPY.example":      true,
+		"http://chirpy.example":       false,
+		"https://evil.example":        false,
+		"https://chirpy.example:8443": false,
+	}
+	for origin, want := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if got := c.checkOrigin(r); got != want {
+			t.Errorf("checkOrigin(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestWebSocketTicketWorksOnce(t *testing.T) {
+	var tickets wsTickets
+	claims := auth.Claims{UserID: uuid.New()}
+	ticket, err := tickets.issue(claims)
+	if err != nil {
+		t.Fatalf("Failed to issue ticket: %v", err)
+	}
+	if got, ok := tickets.redeem(ticket); !ok || got.UserID != claims.UserID {
+		t.Errorf("Expected the ticket to redeem for its claims, got %+v, %v", got, ok)
+	}
+	if _, ok := tickets.redeem(ticket); ok {
+		t.Errorf("Expected a redeemed ticket to be refused")
+	}
+}